@@ -5,9 +5,12 @@ package appetizer
 import (
 	"context"
 	stdErrors "errors"
+	"io"
+	"log/slog"
 	"sync"
 
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
 	"github.com/sourcegraph/conc/pool"
 
 	"github.com/homier/appetizer/log"
@@ -15,7 +18,9 @@ import (
 )
 
 var (
-	ErrStarted = errors.New("application is already started")
+	ErrStarted           = errors.New("application is already started")
+	ErrServiceNotFound   = errors.New("service not found")
+	ErrServiceNotRunning = errors.New("service is not running")
 )
 
 type App struct {
@@ -28,9 +33,39 @@ type App struct {
 	// Configure app to run in debug mode. Will set logger level to `zerolog.DebugLevel`.
 	Debug bool
 
+	// A logger to use as a base for the application and service loggers.
+	// If set, `SlogHandler`, `LogFile`, `LogOutput` and `LogFormat` are ignored.
+	Logger *log.Logger
+
+	// If set, the application and service logs are written to this handler.
+	// `LogFile`, `LogOutput` and `LogFormat` are ignored in that case.
+	SlogHandler slog.Handler
+
+	// Where to write logs. The output is scoped to this application,
+	// so several applications in one process don't interfere.
+	// If nil, the default logging output is used, see `log.DefaultOutput`.
+	LogOutput io.Writer
+
+	// If set, logs are written to this file instead of `LogOutput`.
+	// While the application is running, the file is reopened on SIGHUP,
+	// which makes it compatible with logrotate.
+	LogFile *log.File
+
+	// Log format. If empty, `log.FormatConsole` is used.
+	LogFormat log.Format
+
 	log     log.Logger
 	logOnce sync.Once
 
+	levels    map[string]*log.LevelVar
+	loggers   map[string]log.Logger
+	loggersMu sync.Mutex
+
+	statuses   map[string]ServiceStatus
+	restarts   map[string]*serviceRestart
+	shutdown   context.CancelFunc
+	statusesMu sync.Mutex
+
 	startedWaiter Waiter
 }
 
@@ -69,6 +104,9 @@ func (a *App) RunCh(ctx context.Context) <-chan error {
 		return errCh
 	}
 
+	ctx, shutdown := context.WithCancel(ctx)
+	a.setShutdown(shutdown)
+
 	pool := pool.New().WithContext(ctx).
 		WithCancelOnError().
 		WithFirstError().
@@ -92,11 +130,32 @@ func (a *App) RunCh(ctx context.Context) <-chan error {
 	a.log.Debug().Msg("app: run: pool: waiting for all services to be started")
 	readyWg.Wait()
 
-	a.log.Info().Msg("app: run: started")
+	build := a.BuildInfo()
+	a.log.Info().
+		Str("go_version", build.GoVersion).
+		Str("version", build.Main.Version).
+		Str("vcs_revision", build.VCSRevision).
+		Bool("vcs_modified", build.VCSModified).
+		Msg("app: run: started")
 	a.startedWaiter.Set(true)
 
+	systemdDone := make(chan struct{})
+	go func() {
+		defer close(systemdDone)
+
+		a.notifySystemd(ctx)
+	}()
+
+	reopenCtx, stopReopen := context.WithCancel(ctx)
+	if a.LogFile != nil {
+		go a.LogFile.ReopenOnSignal(reopenCtx)
+	}
+
 	go func() {
 		defer close(errCh)
+		defer func() { <-systemdDone }()
+		defer stopReopen()
+		defer shutdown()
 		defer func() { a.startedWaiter.Set(false) }()
 
 		if err := pool.Wait(); err != nil {
@@ -117,6 +176,85 @@ func (a *App) Log() *log.Logger {
 	return &log
 }
 
+// Returns the current log level of the specified service.
+func (a *App) LogLevel(service string) (zerolog.Level, error) {
+	a.ensureLog()
+
+	srv, err := a.findService(service)
+	if err != nil {
+		return zerolog.NoLevel, err
+	}
+
+	level, err := a.levelVar(srv)
+	if err != nil {
+		return zerolog.NoLevel, err
+	}
+
+	return level.Level(), nil
+}
+
+// Changes the log level of the specified service at runtime.
+// The change is applied immediately, even if the service is already running,
+// and is kept across application restarts.
+func (a *App) SetLogLevel(service string, level zerolog.Level) error {
+	a.ensureLog()
+
+	srv, err := a.findService(service)
+	if err != nil {
+		return err
+	}
+
+	levelVar, err := a.levelVar(srv)
+	if err != nil {
+		return err
+	}
+
+	levelVar.Set(level)
+	a.log.Info().Msgf("app: service: '%s': log level set to '%s'", service, level)
+
+	return nil
+}
+
+// Stops the running application gracefully, as if its `Run` context
+// was cancelled. It's a no-op if the application isn't running.
+func (a *App) Shutdown() {
+	a.statusesMu.Lock()
+	shutdown := a.shutdown
+	a.statusesMu.Unlock()
+
+	if shutdown != nil {
+		a.log.Info().Msg("app: shutdown: requested")
+		shutdown()
+	}
+}
+
+// Restarts the running service: its `Run` context is cancelled,
+// and once `Run` returns, it's called again, regardless of the restart policy.
+// `Init` isn't called again, so the servicer must support consecutive runs,
+// as the servicers of the services package do.
+// Returns an error wrapping either `ErrServiceNotFound`, or `ErrServiceNotRunning`.
+func (a *App) RestartService(name string) error {
+	if _, err := a.findService(name); err != nil {
+		return err
+	}
+
+	a.statusesMu.Lock()
+	restart, ok := a.restarts[name]
+	if ok {
+		restart.requested = true
+	}
+	a.statusesMu.Unlock()
+
+	if !ok {
+		return errors.Wrapf(ErrServiceNotRunning, "service '%s'", name)
+	}
+
+	a.log.Info().Msgf("app: service: '%s': restart requested", name)
+	restart.cancel()
+
+	return nil
+}
+
 // Blocks until the app is started,
 // or the provided context is either cancelled or timed out.
 // If context is cancelled/timed out, a context error is returning,
@@ -139,15 +277,28 @@ func (a *App) init() (errs error) {
 	}
 
 	for _, service := range a.Services {
-		log := a.serviceLogger(service.Name)
+		log, err := a.serviceLogger(service)
+		if err != nil {
+			a.log.Debug().Err(err).Msgf("app: init: service: '%s': invalid log level", service.Name)
+			errs = stdErrors.Join(errs, err)
+			continue
+		}
+
+		a.setServiceLogger(service.Name, log)
 
 		a.log.Debug().Msgf("app: init: service: '%s': initializing", service.Name)
+		a.setServiceState(service.Name, ServiceStateInitializing, nil)
+
 		if err := service.Servicer.Init(log); err != nil {
 			log.Debug().Err(err).Msgf("app: init: service: '%s': failed to initialize", service.Name)
+			a.setServiceState(service.Name, ServiceStateFailed, err)
+
 			errs = stdErrors.Join(errs, err)
 			continue
 		}
 
+		a.setServiceState(service.Name, ServiceStateInitialized, nil)
+
 		a.log.Debug().Msgf("app: init: service: '%s': initialized", service.Name)
 	}
 
@@ -155,7 +306,12 @@ func (a *App) init() (errs error) {
 	return
 }
 
+// Runs the service, passing its logger within the context, see `log.FromContext`.
 func (a *App) runService(ctx context.Context, service *Service) (err error) {
+	if logger, ok := a.getServiceLogger(service.Name); ok {
+		ctx = log.WithContext(ctx, logger)
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -169,14 +325,42 @@ func (a *App) runService(ctx context.Context, service *Service) (err error) {
 		enableRestart = false
 	}
 
-	if enableRestart {
-		err = retry.With(ctx, service.Servicer.Run, service.RestartOpts)
-	} else {
-		err = service.Servicer.Run(ctx)
+	attempts := 0
+	run := func(ctx context.Context) error {
+		if attempts > 0 {
+			a.countServiceRestart(service.Name)
+		}
+		attempts++
+
+		a.setServiceState(service.Name, ServiceStateRunning, nil)
+		if err := service.Servicer.Run(ctx); err != nil {
+			a.setServiceState(service.Name, ServiceStateRestarting, err)
+			return err
+		}
+
+		return nil
+	}
+
+	for {
+		runCtx, restarted := a.startServiceRun(ctx, service.Name)
+
+		if enableRestart {
+			err = retry.With(runCtx, run, service.RestartOpts)
+		} else {
+			err = run(runCtx)
+		}
+
+		// The service is restarted on request, unless the application is stopping.
+		if !restarted() || ctx.Err() != nil {
+			break
+		}
 	}
 
 	if err != nil {
+		a.setServiceState(service.Name, ServiceStateFailed, err)
 		err = errors.Wrapf(err, "service '%s' crashed", service.Name)
+	} else {
+		a.setServiceState(service.Name, ServiceStateStopped, nil)
 	}
 
 	return err
@@ -184,13 +368,133 @@ func (a *App) runService(ctx context.Context, service *Service) (err error) {
 
 func (a *App) ensureLog() {
 	a.logOnce.Do(func() {
-		a.log = log.Setup(a.Debug, log.ContextualField{Name: "app", Value: a.Name})
+		field := log.ContextualField{Name: "app", Value: a.Name}
+
+		switch {
+		case a.Logger != nil:
+			a.log = log.EnrichLogger(*a.Logger, a.Debug, field)
+		case a.SlogHandler != nil:
+			a.log = log.EnrichLogger(
+				log.New(log.NewSlogWriter(a.SlogHandler), log.FormatJSON), a.Debug, field,
+			)
+		case a.LogFile != nil:
+			a.log = log.EnrichLogger(log.New(a.LogFile, a.LogFormat), a.Debug, field)
+		case a.LogOutput != nil || a.LogFormat != "":
+			a.log = log.EnrichLogger(log.New(a.LogOutput, a.LogFormat), a.Debug, field)
+		default:
+			a.log = log.Setup(a.Debug, field)
+		}
 	})
 }
 
-func (a *App) serviceLogger(name string) log.Logger {
-	return log.EnrichLogger(a.log, a.Debug, log.ContextualField{
+func (a *App) serviceLogger(service Service) (log.Logger, error) {
+	level, err := a.levelVar(service)
+	if err != nil {
+		return log.Logger{}, err
+	}
+
+	logger := log.EnrichLogger(a.log, false, log.ContextualField{
 		Name:  "service",
-		Value: name,
+		Value: service.Name,
 	})
+
+	logger = log.WithLevelVar(logger, level)
+	return log.WithSampling(logger, service.LogSampling), nil
+}
+
+func (a *App) setServiceLogger(name string, logger log.Logger) {
+	a.loggersMu.Lock()
+	defer a.loggersMu.Unlock()
+
+	if a.loggers == nil {
+		a.loggers = make(map[string]log.Logger, len(a.Services))
+	}
+
+	a.loggers[name] = logger
+}
+
+func (a *App) getServiceLogger(name string) (log.Logger, bool) {
+	a.loggersMu.Lock()
+	defer a.loggersMu.Unlock()
+
+	logger, ok := a.loggers[name]
+	return logger, ok
+}
+
+// Returns the runtime log level of the service, creating it if needed.
+// A newly created level is set to `Service.LogLevel`, or to the application
+// logger level if `Service.LogLevel` is empty.
+func (a *App) levelVar(service Service) (*log.LevelVar, error) {
+	a.loggersMu.Lock()
+	defer a.loggersMu.Unlock()
+
+	if level, ok := a.levels[service.Name]; ok {
+		return level, nil
+	}
+
+	level := a.log.GetLevel()
+	if service.LogLevel != "" {
+		parsed, err := zerolog.ParseLevel(service.LogLevel)
+		if err != nil {
+			return nil, errors.Wrapf(err, "service '%s' has invalid log level", service.Name)
+		}
+
+		level = parsed
+	}
+
+	if a.levels == nil {
+		a.levels = make(map[string]*log.LevelVar, len(a.Services))
+	}
+
+	a.levels[service.Name] = log.NewLevelVar(level)
+	return a.levels[service.Name], nil
+}
+
+func (a *App) findService(name string) (Service, error) {
+	for _, service := range a.Services {
+		if service.Name == name {
+			return service, nil
+		}
+	}
+
+	return Service{}, errors.Wrapf(ErrServiceNotFound, "service '%s'", name)
+}
+
+// A pending restart of the running service, see `App.RestartService`.
+type serviceRestart struct {
+	cancel    context.CancelFunc
+	requested bool
+}
+
+func (a *App) setShutdown(shutdown context.CancelFunc) {
+	a.statusesMu.Lock()
+	defer a.statusesMu.Unlock()
+
+	a.shutdown = shutdown
+}
+
+// Returns a context of a single service run, which is cancelled on a restart
+// request, and a function that reports whether a restart was requested.
+func (a *App) startServiceRun(ctx context.Context, name string) (context.Context, func() bool) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	a.statusesMu.Lock()
+	defer a.statusesMu.Unlock()
+
+	if a.restarts == nil {
+		a.restarts = make(map[string]*serviceRestart, len(a.Services))
+	}
+
+	restart := &serviceRestart{cancel: cancel}
+	a.restarts[name] = restart
+
+	return ctx, func() bool {
+		cancel()
+
+		a.statusesMu.Lock()
+		defer a.statusesMu.Unlock()
+
+		delete(a.restarts, name)
+		return restart.requested
+	}
 }
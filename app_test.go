@@ -1,16 +1,24 @@
 package appetizer
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	stdErrors "errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/homier/appetizer/log"
 	"github.com/homier/appetizer/retry"
 )
 
@@ -583,3 +591,272 @@ func TestApp_Wait(t *testing.T) {
 		}
 	})
 }
+
+func TestApp_LogOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	app := &App{Name: t.Name(), LogOutput: buf, LogFormat: log.FormatJSON}
+	app.Log().Info().Msg("hello")
+
+	entry := map[string]any{}
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry)) {
+		assert.Equal(t, t.Name(), entry["app"])
+		assert.Equal(t, "hello", entry["message"])
+	}
+
+	t.Run("Logger", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		logger := zerolog.New(buf)
+
+		app := &App{Name: t.Name(), Logger: &logger, LogOutput: io.Discard}
+		app.Log().Info().Msg("hello")
+
+		assert.Contains(t, buf.String(), t.Name())
+	})
+}
+
+type slogServicer struct {
+	log *slog.Logger
+}
+
+func (s *slogServicer) Init(log *slog.Logger) error {
+	s.log = log
+	return nil
+}
+
+func (s *slogServicer) Run(_ context.Context) error {
+	s.log.Info("ran")
+	return nil
+}
+
+func TestApp_SlogHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	app := &App{
+		Name:        t.Name(),
+		SlogHandler: slog.NewJSONHandler(buf, nil),
+		Services: []Service{{
+			Name:     "srv",
+			Servicer: SlogService(&slogServicer{}),
+		}},
+	}
+
+	if !assert.NoError(t, app.Run(context.Background())) {
+		return
+	}
+
+	found := false
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		entry := map[string]any{}
+		if !assert.NoError(t, json.Unmarshal(line, &entry)) {
+			return
+		}
+
+		assert.Equal(t, t.Name(), entry["app"])
+		if entry["msg"] == "ran" {
+			found = true
+			assert.Equal(t, "srv", entry["service"])
+		}
+	}
+
+	assert.True(t, found)
+}
+
+func TestApp_SetLogLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	var srvLog log.Logger
+	srv := NewMockServicer(t)
+	srv.EXPECT().Init(mock.AnythingOfType("zerolog.Logger")).RunAndReturn(func(l log.Logger) error {
+		srvLog = l
+		return nil
+	}).Once()
+
+	app := &App{
+		Name:      t.Name(),
+		LogOutput: buf,
+		LogFormat: log.FormatJSON,
+		Services:  []Service{{Name: "srv", Servicer: srv, LogLevel: "warn"}},
+	}
+	app.ensureLog()
+
+	if !assert.NoError(t, app.init()) {
+		return
+	}
+
+	level, err := app.LogLevel("srv")
+	assert.NoError(t, err)
+	assert.Equal(t, zerolog.WarnLevel, level)
+
+	srvLog.Info().Msg("skipped")
+	assert.NotContains(t, buf.String(), "skipped")
+
+	assert.NoError(t, app.SetLogLevel("srv", zerolog.DebugLevel))
+	srvLog.Debug().Msg("written")
+	assert.Contains(t, buf.String(), "written")
+
+	assert.ErrorIs(t, app.SetLogLevel("unknown", zerolog.DebugLevel), ErrServiceNotFound)
+
+	t.Run("Invalid level", func(t *testing.T) {
+		app := &App{
+			Name:     t.Name(),
+			Services: []Service{{Name: "srv", Servicer: NewMockServicer(t), LogLevel: "loud"}},
+		}
+
+		assert.ErrorContains(t, app.init(), "invalid log level")
+	})
+}
+
+func TestApp_LogOutputScoped(t *testing.T) {
+	bufs := [2]*bytes.Buffer{{}, {}}
+	apps := [2]*App{}
+
+	for i := range apps {
+		apps[i] = &App{
+			Name:      fmt.Sprintf("%s_%d", t.Name(), i),
+			LogOutput: bufs[i],
+			LogFormat: log.FormatJSON,
+		}
+	}
+
+	log.Enable()
+	apps[0].Log().Info().Msg("hello")
+	log.Disable()
+	apps[1].Log().Info().Msg("hello")
+
+	for i, buf := range bufs {
+		assert.Contains(t, buf.String(), apps[i].Name)
+		assert.NotContains(t, buf.String(), apps[1-i].Name)
+	}
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestApp_ContextLogger(t *testing.T) {
+	buf := &syncBuffer{}
+
+	srv := NewMockServicer(t)
+	srv.EXPECT().Init(mock.AnythingOfType("zerolog.Logger")).Return(nil).Once()
+	srv.EXPECT().Run(mock.AnythingOfType("*context.cancelCtx")).RunAndReturn(func(ctx context.Context) error {
+		logger := log.FromContext(ctx)
+		logger.Info().Msg("from context")
+
+		return nil
+	}).Once()
+
+	app := &App{
+		Name:      t.Name(),
+		LogOutput: buf,
+		LogFormat: log.FormatJSON,
+		Services:  []Service{{Name: "srv", Servicer: srv}},
+	}
+
+	if assert.NoError(t, app.Run(context.Background())) {
+		assert.Contains(t, buf.String(), `"service":"srv","time"`)
+		assert.Contains(t, buf.String(), "from context")
+	}
+}
+
+func TestApp_Status(t *testing.T) {
+	failing := NewMockServicer(t)
+	failing.EXPECT().Init(mock.AnythingOfType("zerolog.Logger")).Return(nil).Once()
+	failing.EXPECT().Run(mock.AnythingOfType("*context.cancelCtx")).
+		Return(errors.New("boom")).Times(3)
+
+	app := &App{
+		Name: t.Name(),
+		Services: []Service{{
+			Name:           "failing",
+			Servicer:       failing,
+			RestartEnabled: true,
+			RestartOpts: retry.Opts{
+				Opts:     backoff.NewConstantBackOff(time.Millisecond),
+				MaxRetry: 2,
+			},
+		}},
+	}
+
+	status := app.Status()
+	assert.Equal(t, t.Name(), status.Name)
+	assert.False(t, status.Started)
+	assert.Equal(t, []ServiceStatus{{Name: "failing", State: ServiceStatePending}}, status.Services)
+
+	assert.ErrorContains(t, app.Run(context.Background()), "boom")
+
+	status = app.Status()
+	if !assert.Len(t, status.Services, 1) {
+		return
+	}
+
+	assert.Equal(t, ServiceStateFailed, status.Services[0].State)
+	assert.Equal(t, uint64(2), status.Services[0].Restarts)
+	assert.Equal(t, "boom", status.Services[0].Error)
+	assert.False(t, status.Services[0].Since.IsZero())
+
+	t.Run("Stopped", func(t *testing.T) {
+		srv := NewMockServicer(t)
+		srv.EXPECT().Init(mock.AnythingOfType("zerolog.Logger")).Return(nil).Once()
+		srv.EXPECT().Run(mock.AnythingOfType("*context.cancelCtx")).Return(nil).Once()
+
+		app := &App{Name: t.Name(), Services: []Service{{Name: "srv", Servicer: srv}}}
+		assert.NoError(t, app.Run(context.Background()))
+
+		status := app.Status()
+		assert.Equal(t, ServiceStateStopped, status.Services[0].State)
+		assert.Empty(t, status.Services[0].Error)
+	})
+}
+
+func TestApp_RestartService(t *testing.T) {
+	runs := make(chan struct{}, 2)
+
+	srv := NewMockServicer(t)
+	srv.EXPECT().Init(mock.AnythingOfType("zerolog.Logger")).Return(nil).Once()
+	srv.EXPECT().Run(mock.AnythingOfType("*context.cancelCtx")).RunAndReturn(func(ctx context.Context) error {
+		runs <- struct{}{}
+		<-ctx.Done()
+
+		return nil
+	}).Twice()
+
+	app := &App{Name: t.Name(), Services: []Service{{Name: "srv", Servicer: srv}}}
+
+	assert.ErrorIs(t, app.RestartService("unknown"), ErrServiceNotFound)
+	assert.ErrorIs(t, app.RestartService("srv"), ErrServiceNotRunning)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	runCh := app.RunCh(ctx)
+
+	<-runs
+	assert.NoError(t, app.RestartService("srv"))
+	<-runs
+
+	status := app.Status()
+	assert.Equal(t, uint64(1), status.Services[0].Restarts)
+	assert.Equal(t, ServiceStateRunning, status.Services[0].State)
+
+	app.Shutdown()
+	assert.NoError(t, <-runCh)
+	assert.NoError(t, ctx.Err())
+
+	assert.Equal(t, ServiceStateStopped, app.Status().Services[0].State)
+}
@@ -0,0 +1,98 @@
+package appetizer
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Build information of the running binary.
+type BuildInfo struct {
+	// Go version the binary was built with.
+	GoVersion string `json:"go_version"`
+
+	// Path of the main package, e.g. "github.com/homier/app/cmd/app".
+	Path string `json:"path"`
+
+	// The main module, which version is "(devel)" unless
+	// the binary was installed with `go install module@version`.
+	Main Module `json:"main"`
+
+	// VCS information, available if the binary was built within a repository,
+	// see `go help buildvcs`.
+	VCS         string    `json:"vcs,omitempty"`
+	VCSRevision string    `json:"vcs_revision,omitempty"`
+	VCSTime     time.Time `json:"vcs_time"`
+	VCSModified bool      `json:"vcs_modified"`
+
+	// Modules the binary depends on.
+	Dependencies []Module `json:"dependencies,omitempty"`
+}
+
+// A module the binary was built with.
+type Module struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Sum     string `json:"sum,omitempty"`
+
+	// A module that replaced this one, if any.
+	Replace *Module `json:"replace,omitempty"`
+}
+
+var buildInfo = sync.OnceValue(func() BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return BuildInfo{}
+	}
+
+	return newBuildInfo(info)
+})
+
+// Returns build information of the running binary, see runtime/debug.ReadBuildInfo.
+// The information is empty if the binary was built without module support.
+func ReadBuildInfo() BuildInfo {
+	return buildInfo()
+}
+
+// Returns build information of the running binary, see `ReadBuildInfo`.
+func (a *App) BuildInfo() BuildInfo {
+	return ReadBuildInfo()
+}
+
+func newBuildInfo(info *debug.BuildInfo) BuildInfo {
+	build := BuildInfo{
+		GoVersion:    info.GoVersion,
+		Path:         info.Path,
+		Main:         newModule(&info.Main),
+		Dependencies: make([]Module, 0, len(info.Deps)),
+	}
+
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs":
+			build.VCS = setting.Value
+		case "vcs.revision":
+			build.VCSRevision = setting.Value
+		case "vcs.time":
+			build.VCSTime, _ = time.Parse(time.RFC3339, setting.Value)
+		case "vcs.modified":
+			build.VCSModified = setting.Value == "true"
+		}
+	}
+
+	for _, dep := range info.Deps {
+		build.Dependencies = append(build.Dependencies, newModule(dep))
+	}
+
+	return build
+}
+
+func newModule(module *debug.Module) Module {
+	m := Module{Path: module.Path, Version: module.Version, Sum: module.Sum}
+	if module.Replace != nil {
+		replace := newModule(module.Replace)
+		m.Replace = &replace
+	}
+
+	return m
+}
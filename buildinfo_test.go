@@ -0,0 +1,85 @@
+package appetizer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"runtime"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/homier/appetizer/log"
+)
+
+func TestNewBuildInfo(t *testing.T) {
+	info := newBuildInfo(&debug.BuildInfo{
+		GoVersion: "go1.22.5",
+		Path:      "example.com/app/cmd/app",
+		Main:      debug.Module{Path: "example.com/app", Version: "v1.2.3", Sum: "h1:main"},
+		Deps: []*debug.Module{
+			{Path: "example.com/dep", Version: "v0.1.0", Sum: "h1:dep"},
+			{Path: "example.com/old", Version: "v1.0.0", Replace: &debug.Module{Path: "../old", Version: "(devel)"}},
+		},
+		Settings: []debug.BuildSetting{
+			{Key: "-trimpath", Value: "true"},
+			{Key: "vcs", Value: "git"},
+			{Key: "vcs.revision", Value: "c351d40"},
+			{Key: "vcs.time", Value: "2024-08-01T10:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	})
+
+	assert.Equal(t, BuildInfo{
+		GoVersion:   "go1.22.5",
+		Path:        "example.com/app/cmd/app",
+		Main:        Module{Path: "example.com/app", Version: "v1.2.3", Sum: "h1:main"},
+		VCS:         "git",
+		VCSRevision: "c351d40",
+		VCSTime:     time.Date(2024, 8, 1, 10, 0, 0, 0, time.UTC),
+		VCSModified: true,
+		Dependencies: []Module{
+			{Path: "example.com/dep", Version: "v0.1.0", Sum: "h1:dep"},
+			{Path: "example.com/old", Version: "v1.0.0", Replace: &Module{Path: "../old", Version: "(devel)"}},
+		},
+	}, info)
+}
+
+func TestApp_BuildInfo(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	srv := NewMockServicer(t)
+	srv.EXPECT().Init(mock.AnythingOfType("zerolog.Logger")).Return(nil).Once()
+	srv.EXPECT().Run(mock.AnythingOfType("*context.cancelCtx")).Return(nil).Once()
+
+	app := &App{
+		Name:      t.Name(),
+		LogOutput: buf,
+		LogFormat: log.FormatJSON,
+		Services:  []Service{{Name: "srv", Servicer: srv}},
+	}
+
+	info := app.BuildInfo()
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Equal(t, ReadBuildInfo(), info)
+
+	assert.NoError(t, app.Run(context.Background()))
+
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		entry := map[string]any{}
+		if !assert.NoError(t, json.Unmarshal(line, &entry)) || entry["message"] != "app: run: started" {
+			continue
+		}
+
+		assert.Equal(t, info.GoVersion, entry["go_version"])
+		assert.Equal(t, info.Main.Version, entry["version"])
+		assert.Contains(t, entry, "vcs_revision")
+		assert.Contains(t, entry, "vcs_modified")
+		return
+	}
+
+	t.Error("startup log line is not found")
+}
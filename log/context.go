@@ -0,0 +1,23 @@
+package log
+
+import (
+	"context"
+)
+
+type contextKey struct{}
+
+// Returns a copy of `ctx` carrying the provided logger.
+func WithContext(ctx context.Context, log Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, log)
+}
+
+// Returns a logger carried by `ctx`, see `WithContext`.
+// If there is none, a new console logger writing to the default
+// logging output is returned.
+func FromContext(ctx context.Context) Logger {
+	if log, ok := ctx.Value(contextKey{}).(Logger); ok {
+		return log
+	}
+
+	return New(nil, FormatConsole)
+}
@@ -0,0 +1,25 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	log := New(buf, FormatJSON).With().Str("request_id", "42").Logger()
+	ctx := WithContext(context.Background(), log)
+
+	logger := FromContext(ctx)
+	logger.Info().Msg("hello")
+	assert.Contains(t, buf.String(), `"request_id":"42"`)
+
+	assert.NotPanics(t, func() {
+		logger := FromContext(context.Background())
+		logger.Info().Msg("hello")
+	})
+}
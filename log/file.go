@@ -0,0 +1,364 @@
+package log
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	DefaultFileMode os.FileMode = 0o600
+
+	// A time layout used as a suffix of rotated file names.
+	// Files rotated within the same millisecond get a "-<n>" counter
+	// after the timestamp, so they never overwrite each other.
+	BackupTimeLayout = "2006-01-02T15-04-05.000"
+)
+
+const compressedSuffix = ".gz"
+
+// An `io.Writer` that writes to a file with size- and age-based rotation.
+// A rotated file is renamed to `<Path>.<timestamp>[-<n>]`, and is compressed
+// to `<Path>.<timestamp>.gz` if `Compress` is enabled.
+//
+// The file is opened on first write, and it's safe for concurrent use.
+// For logrotate compatibility, use `Reopen` or `ReopenOnSignal`.
+type File struct {
+	// Path to the log file. Parent directories are created if needed.
+	Path string
+
+	// Maximum size of the file in bytes before it gets rotated.
+	// If zero, the file isn't rotated by size.
+	MaxSize int64
+
+	// Maximum age of the file before it gets rotated,
+	// counted from the moment the file is opened.
+	// If zero, the file isn't rotated by age.
+	MaxAge time.Duration
+
+	// Maximum number of rotated files to keep, older ones are removed.
+	// If zero, all rotated files are kept.
+	MaxBackups int
+
+	// Whether to compress rotated files with gzip or not.
+	Compress bool
+
+	// Permissions of a newly created file.
+	// If zero, the `DefaultFileMode` will be used.
+	Mode os.FileMode
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	mu       sync.Mutex
+
+	// Serializes compression and cleanup of rotated files.
+	backupsMu sync.Mutex
+	backupsWg sync.WaitGroup
+}
+
+var _ io.WriteCloser = (*File)(nil)
+
+// Write implements io.Writer, rotating the file if needed.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if f.shouldRotate(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// Rotates the file immediately.
+func (f *File) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.rotate()
+}
+
+// Closes and opens the file again by its path.
+// Useful when the file has been moved by an external tool like logrotate.
+func (f *File) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.close(); err != nil {
+		return err
+	}
+
+	return f.open()
+}
+
+// Reopens the file on every of the provided signals, blocking until
+// the context is done. If no signals are provided, SIGHUP is used.
+func (f *File) ReopenOnSignal(ctx context.Context, signals ...os.Signal) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	defer signal.Stop(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+			if err := f.Reopen(); err != nil {
+				// The logger itself might be writing to this file,
+				// so there is nowhere else to report the error.
+				_, _ = io.WriteString(os.Stderr, "log: failed to reopen log file: "+err.Error()+"\n")
+			}
+		}
+	}
+}
+
+// Closes the file, waiting for the rotated files to be compressed.
+// The file will be opened again on the next write.
+func (f *File) Close() error {
+	f.mu.Lock()
+	err := f.close()
+	f.mu.Unlock()
+
+	f.backupsWg.Wait()
+	return err
+}
+
+func (f *File) open() error {
+	if f.Path == "" {
+		return errors.New("log file path is not defined")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o750); err != nil {
+		return errors.Wrap(err, "failed to create log file directory")
+	}
+
+	mode := f.Mode
+	if mode == 0 {
+		mode = DefaultFileMode
+	}
+
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, mode)
+	if err != nil {
+		return errors.Wrap(err, "failed to open log file")
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, "failed to stat log file")
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = time.Now()
+
+	return nil
+}
+
+func (f *File) close() error {
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	return errors.Wrap(err, "failed to close log file")
+}
+
+func (f *File) shouldRotate(size int64) bool {
+	if f.MaxSize > 0 && f.size > 0 && f.size+size > f.MaxSize {
+		return true
+	}
+
+	return f.MaxAge > time.Duration(0) && time.Since(f.openedAt) >= f.MaxAge
+}
+
+func (f *File) rotate() error {
+	if err := f.close(); err != nil {
+		return err
+	}
+
+	backup := f.backupPath(time.Now())
+	if err := os.Rename(f.Path, backup); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to rename log file")
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.backupsWg.Add(1)
+	go func() {
+		defer f.backupsWg.Done()
+
+		f.processBackups(backup)
+	}()
+
+	return nil
+}
+
+// Compresses the rotated file if needed and removes excess rotated files.
+func (f *File) processBackups(backup string) {
+	f.backupsMu.Lock()
+	defer f.backupsMu.Unlock()
+
+	if f.Compress {
+		if err := compressFile(backup); err != nil {
+			_, _ = io.WriteString(os.Stderr, "log: failed to compress rotated log file: "+err.Error()+"\n")
+		}
+	}
+
+	if f.MaxBackups <= 0 {
+		return
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < len(backups)-f.MaxBackups; i++ {
+		_ = os.Remove(backups[i])
+	}
+}
+
+// Returns a path to rename the file to when rotated at `now`,
+// adding a counter if it's taken by a file rotated within the same millisecond.
+func (f *File) backupPath(now time.Time) string {
+	base := f.Path + "." + now.Format(BackupTimeLayout)
+
+	backup := base
+	for n := 1; fileExists(backup) || fileExists(backup+compressedSuffix); n++ {
+		backup = base + "-" + strconv.Itoa(n)
+	}
+
+	return backup
+}
+
+// Returns rotated files sorted from the oldest to the newest.
+func (f *File) backups() ([]string, error) {
+	matches, err := filepath.Glob(f.Path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	type backup struct {
+		path      string
+		timestamp string
+		n         int
+	}
+
+	backups := make([]backup, 0, len(matches))
+	for _, match := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(match, f.Path+"."), compressedSuffix)
+		if timestamp, n, ok := parseBackupSuffix(suffix); ok {
+			backups = append(backups, backup{path: match, timestamp: timestamp, n: n})
+		}
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].timestamp != backups[j].timestamp {
+			return backups[i].timestamp < backups[j].timestamp
+		}
+
+		return backups[i].n < backups[j].n
+	})
+
+	paths := make([]string, 0, len(backups))
+	for _, backup := range backups {
+		paths = append(paths, backup.path)
+	}
+
+	return paths, nil
+}
+
+// Parses a `<timestamp>[-<n>]` suffix of a rotated file name.
+func parseBackupSuffix(suffix string) (string, int, bool) {
+	if len(suffix) < len(BackupTimeLayout) {
+		return "", 0, false
+	}
+
+	timestamp, counter := suffix[:len(BackupTimeLayout)], suffix[len(BackupTimeLayout):]
+	if _, err := time.Parse(BackupTimeLayout, timestamp); err != nil {
+		return "", 0, false
+	}
+
+	if counter == "" {
+		return timestamp, 0, true
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(counter, "-"))
+	if err != nil || n <= 0 || counter[0] != '-' {
+		return "", 0, false
+	}
+
+	return timestamp, n, true
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func compressFile(path string) error {
+	if err := gzipFile(path, path+compressedSuffix); err != nil {
+		_ = os.Remove(path + compressedSuffix)
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+func gzipFile(src, dst string) error {
+	in, err := os.Open(filepath.Clean(src))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(filepath.Clean(dst), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, DefaultFileMode)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+
+	if err := gz.Close(); err != nil {
+		_ = out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,138 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile_Write(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+
+	f := &File{Path: path, MaxSize: 10, MaxBackups: 2}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "fourth\n", string(content))
+
+	backups, err := f.backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+
+	content, err = os.ReadFile(backups[0])
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", string(content))
+}
+
+func TestFile_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	f := &File{Path: path}
+	defer f.Close()
+
+	// Files rotated within the same millisecond are all kept in order.
+	for i := range 12 {
+		_, err := f.Write([]byte(strconv.Itoa(i)))
+		require.NoError(t, err)
+		require.NoError(t, f.Rotate())
+	}
+	require.NoError(t, f.Close())
+
+	backups, err := f.backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 12)
+
+	for i, backup := range backups {
+		content, err := os.ReadFile(backup)
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(i), string(content))
+	}
+}
+
+func TestFile_Compress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	f := &File{Path: path, Compress: true}
+	defer f.Close()
+
+	_, err := f.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Rotate())
+	require.NoError(t, f.Close())
+
+	backups, err := f.backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	require.True(t, strings.HasSuffix(backups[0], compressedSuffix))
+
+	file, err := os.Open(backups[0])
+	require.NoError(t, err)
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(content))
+}
+
+func TestFile_MaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	f := &File{Path: path, MaxAge: time.Millisecond * 5}
+	defer f.Close()
+
+	_, err := f.Write([]byte("old\n"))
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 10)
+
+	_, err = f.Write([]byte("new\n"))
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(content))
+}
+
+func TestFile_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	f := &File{Path: path}
+	defer f.Close()
+
+	_, err := f.Write([]byte("before\n"))
+	require.NoError(t, err)
+
+	moved := filepath.Join(dir, "app.log.1")
+	require.NoError(t, os.Rename(path, moved))
+	require.NoError(t, f.Reopen())
+
+	_, err = f.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "after\n", string(content))
+
+	content, err = os.ReadFile(moved)
+	require.NoError(t, err)
+	assert.Equal(t, "before\n", string(content))
+}
@@ -0,0 +1,79 @@
+package log
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+// A logging level that could be changed at runtime.
+// It's safe for concurrent use. The zero value is `zerolog.DebugLevel`.
+type LevelVar struct {
+	level atomic.Int32
+}
+
+var _ zerolog.Sampler = (*LevelVar)(nil)
+
+type levelVarKey struct{}
+
+// Returns a new `LevelVar` set to the provided level.
+func NewLevelVar(level zerolog.Level) *LevelVar {
+	v := &LevelVar{}
+	v.Set(level)
+
+	return v
+}
+
+// Returns the current level.
+func (v *LevelVar) Level() zerolog.Level {
+	return zerolog.Level(v.level.Load())
+}
+
+// Sets the current level.
+func (v *LevelVar) Set(level zerolog.Level) {
+	v.level.Store(int32(level))
+}
+
+// Sample implements zerolog.Sampler, rejecting events below the current level,
+// so they're never built, like the ones below the logger level.
+func (v *LevelVar) Sample(level zerolog.Level) bool {
+	return level >= v.Level()
+}
+
+// Returns a copy of provided `log` whose level is controlled by `level`.
+// Changes of `level` are applied to every logger derived from the returning one.
+// The level is the logger sampler, so it replaces the `log` one,
+// use `SamplingOpts.Sampler` to sample messages on top of it.
+// The logger level is reported by `GetLevel`.
+func WithLevelVar(log Logger, level *LevelVar) Logger {
+	ctx := context.WithValue(loggerContext(log), levelVarKey{}, level)
+	return log.Level(zerolog.TraceLevel).Sample(level).With().Ctx(ctx).Logger()
+}
+
+// Returns the level of provided `log`, which is the current level
+// of its `LevelVar`, if any, see `WithLevelVar`.
+func GetLevel(log Logger) zerolog.Level {
+	if level := levelVarOf(log); level != nil {
+		return level.Level()
+	}
+
+	return log.GetLevel()
+}
+
+func levelVarOf(log Logger) *LevelVar {
+	level, _ := loggerContext(log).Value(levelVarKey{}).(*LevelVar)
+	return level
+}
+
+// Returns the context of provided `log`, see `zerolog.Context.Ctx`.
+// It's only available through an event, so one is created and dropped,
+// bypassing the logger level and sampler.
+func loggerContext(log Logger) context.Context {
+	probe := log.Level(zerolog.TraceLevel).Sample(nil)
+
+	event := probe.Log()
+	defer event.Discard()
+
+	return event.GetCtx()
+}
@@ -0,0 +1,49 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithLevelVar(t *testing.T) {
+	buf := &bytes.Buffer{}
+	level := NewLevelVar(zerolog.InfoLevel)
+
+	log := WithLevelVar(New(buf, FormatJSON), level).With().Str("child", "true").Logger()
+
+	// Events below the level aren't even built.
+	assert.Nil(t, log.Debug())
+	assert.Equal(t, zerolog.InfoLevel, GetLevel(log))
+
+	level.Set(zerolog.DebugLevel)
+	log.Debug().Msg("written")
+	assert.Contains(t, buf.String(), "written")
+
+	buf.Reset()
+	level.Set(zerolog.ErrorLevel)
+	log.Warn().Msg("skipped")
+	assert.Zero(t, buf.Len())
+	assert.Equal(t, zerolog.ErrorLevel, GetLevel(log))
+
+	assert.Equal(t, zerolog.WarnLevel, GetLevel(New(buf, FormatJSON).Level(zerolog.WarnLevel)))
+}
+
+func TestSlogHandler_LevelVar(t *testing.T) {
+	level := NewLevelVar(zerolog.InfoLevel)
+	handler := NewSlogHandler(WithLevelVar(New(&bytes.Buffer{}, FormatJSON), level)).
+		WithAttrs([]slog.Attr{slog.String("key", "value")})
+
+	assert.False(t, handler.Enabled(context.Background(), slog.LevelDebug))
+	assert.True(t, handler.Enabled(context.Background(), slog.LevelInfo))
+
+	level.Set(zerolog.DebugLevel)
+	assert.True(t, handler.Enabled(context.Background(), slog.LevelDebug))
+
+	level.Set(zerolog.WarnLevel)
+	assert.False(t, handler.Enabled(context.Background(), slog.LevelInfo))
+}
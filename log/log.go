@@ -9,21 +9,23 @@ import (
 	"github.com/rs/zerolog"
 )
 
-var (
-	outStream io.Writer = os.Stderr
-	writer              = zerolog.ConsoleWriter{
-		Out:        outStream,
-		TimeFormat: time.RFC3339Nano,
-		NoColor:    false,
-	}
+// Log output format.
+type Format string
 
-	log = zerolog.New(writer).
-		With().
-		Timestamp().
-		Logger().
-		Level(zerolog.InfoLevel)
+const (
+	// Human-friendly colored output, see `zerolog.ConsoleWriter`.
+	FormatConsole Format = "console"
 
-	mu sync.Mutex
+	// One JSON object per line, suitable for log shipping.
+	FormatJSON Format = "json"
+)
+
+// The default logging output, used by loggers created without an explicit one.
+// Kept for backwards compatibility only, prefer configuring an output
+// for every application, see `appetizer.App.LogOutput`.
+var (
+	outStream io.Writer = os.Stderr
+	mu        sync.Mutex
 )
 
 type ContextualField struct {
@@ -33,13 +35,35 @@ type ContextualField struct {
 
 type Logger = zerolog.Logger
 
-// Returns a copy of default `zerolog.Logger` with specified contextual fields.
+// Returns a new `zerolog.Logger` writing to `out` in the specified format.
+// If `out` is nil, the default logging output is used, see `DefaultOutput`.
+// If `format` is empty, `FormatConsole` is used.
+// A returning logger is configured to `zerolog.InfoLevel` level.
+func New(out io.Writer, format Format) Logger {
+	if out == nil {
+		out = DefaultOutput()
+	}
+
+	if format != FormatJSON {
+		out = zerolog.ConsoleWriter{
+			Out:        out,
+			TimeFormat: time.RFC3339Nano,
+			NoColor:    false,
+		}
+	}
+
+	return zerolog.New(out).
+		With().
+		Timestamp().
+		Logger().
+		Level(zerolog.InfoLevel)
+}
+
+// Returns a new console logger writing to the default logging output
+// with specified contextual fields.
 // If `debug` is true, a returning logger will be configured to `zerolog.DebugLevel` level.
 func Setup(debug bool, fields ...ContextualField) Logger {
-	mu.Lock()
-	defer mu.Unlock()
-
-	return EnrichLogger(log, debug, fields...)
+	return EnrichLogger(New(nil, FormatConsole), debug, fields...)
 }
 
 // Returns a copy of provided `log` with additional contextual fields.
@@ -60,20 +84,28 @@ func EnrichLogger(log Logger, debug bool, fields ...ContextualField) Logger {
 	return logger
 }
 
-// Sets global logging output to `os.Stderr`.
+// Returns the default logging output, `os.Stderr` unless `Disable` was called.
+func DefaultOutput() io.Writer {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return outStream
+}
+
+// Sets the default logging output to `os.Stderr`.
+// Only loggers created afterwards are affected.
 func Enable() {
 	mu.Lock()
 	defer mu.Unlock()
 
 	outStream = os.Stderr
-	log = log.Output(outStream)
 }
 
-// Sets global logging output to `io.Discard`, meaning no log messages will be produced.
+// Sets the default logging output to `io.Discard`, meaning no log messages
+// will be produced by loggers created afterwards without an explicit output.
 func Disable() {
 	mu.Lock()
 	defer mu.Unlock()
 
 	outStream = io.Discard
-	log = log.Output(io.Discard)
 }
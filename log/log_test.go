@@ -1,6 +1,8 @@
 package log
 
 import (
+	"bytes"
+	"encoding/json"
 	"io"
 	"os"
 	"testing"
@@ -17,7 +19,7 @@ func TestEnable(t *testing.T) {
 
 	Enable()
 
-	assert.Equal(t, outStream, os.Stderr)
+	assert.Equal(t, os.Stderr, DefaultOutput())
 }
 
 func TestDisable(t *testing.T) {
@@ -27,10 +29,38 @@ func TestDisable(t *testing.T) {
 	}()
 
 	Disable()
-	assert.Equal(t, outStream, io.Discard)
+	assert.Equal(t, io.Discard, DefaultOutput())
+
+	buf := &bytes.Buffer{}
+	log := New(buf, FormatJSON)
+	log.Info().Msg("hello")
+
+	assert.Contains(t, buf.String(), "hello")
 }
 
 func TestSetup(t *testing.T) {
 	log := Setup(true, ContextualField{Name: "app", Value: t.Name()})
 	assert.Equal(t, zerolog.DebugLevel, log.GetLevel())
 }
+
+func TestNew(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	log := New(buf, FormatJSON)
+	log.Info().Str("key", "value").Msg("hello")
+
+	entry := map[string]any{}
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry)) {
+		assert.Equal(t, "info", entry["level"])
+		assert.Equal(t, "value", entry["key"])
+		assert.Equal(t, "hello", entry["message"])
+		assert.Contains(t, entry, "time")
+	}
+
+	buf.Reset()
+	log = New(buf, FormatConsole)
+	log.Info().Msg("hello")
+
+	assert.Contains(t, buf.String(), "hello")
+	assert.False(t, json.Valid(buf.Bytes()))
+}
@@ -0,0 +1,156 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Sampling and deduplication options of a logger.
+type SamplingOpts struct {
+	// A number of identical messages written per `Period`.
+	// Messages are identical if they have the same level and text.
+	// Once the limit is reached, the rest are suppressed and a summary
+	// with the number of suppressed messages is written at the end of the period.
+	// If zero, deduplication is disabled.
+	Burst uint64
+
+	// Deduplication period. Must be defined if `Burst` is greater than 0.
+	Period time.Duration
+
+	// Messages below this level are never suppressed.
+	Level zerolog.Level
+
+	// An optional zerolog sampler applied to every message,
+	// e.g. `&zerolog.BurstSampler{}`. Unlike `zerolog.Logger.Sample`,
+	// it doesn't replace the logger sampler, e.g. a `LevelVar`, and is
+	// applied after it, so messages below the level don't use up its budget.
+	Sampler zerolog.Sampler
+}
+
+// Returns a copy of provided `log` with sampling and deduplication applied.
+// If `opts` are empty, `log` is returned as is.
+func WithSampling(log Logger, opts SamplingOpts) Logger {
+	if opts.Sampler != nil {
+		log = log.Hook(&samplerHook{sampler: opts.Sampler})
+	}
+
+	if opts.Burst == 0 || opts.Period <= time.Duration(0) {
+		return log
+	}
+
+	return log.Hook(&deduplicator{
+		opts:    opts,
+		log:     log,
+		entries: make(map[dedupKey]*dedupEntry),
+	})
+}
+
+// Applies a sampler to the events that are still enabled.
+type samplerHook struct {
+	sampler zerolog.Sampler
+}
+
+// Run implements zerolog.Hook.
+func (h *samplerHook) Run(e *zerolog.Event, level zerolog.Level, _ string) {
+	if !e.Enabled() || level == zerolog.Disabled {
+		return
+	}
+
+	if !h.sampler.Sample(level) {
+		e.Discard()
+	}
+}
+
+type dedupKey struct {
+	level zerolog.Level
+	msg   string
+}
+
+type dedupEntry struct {
+	start      time.Time
+	count      uint64
+	suppressed uint64
+}
+
+type deduplicator struct {
+	opts SamplingOpts
+
+	// A logger without the deduplicator hook, used to write summaries.
+	log Logger
+
+	mu        sync.Mutex
+	entries   map[dedupKey]*dedupEntry
+	lastSweep time.Time
+}
+
+// Run implements zerolog.Hook.
+func (d *deduplicator) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	// Events discarded by other hooks, e.g. the sampler, are never written.
+	if !e.Enabled() || level == zerolog.Disabled {
+		return
+	}
+
+	if level < d.opts.Level || level == zerolog.NoLevel {
+		return
+	}
+
+	now := time.Now()
+	key := dedupKey{level: level, msg: msg}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sweep(now)
+
+	entry, ok := d.entries[key]
+	if !ok || now.Sub(entry.start) >= d.opts.Period {
+		entry = &dedupEntry{start: now}
+		d.entries[key] = entry
+	}
+
+	entry.count++
+	if entry.count <= d.opts.Burst {
+		return
+	}
+
+	e.Discard()
+
+	entry.suppressed++
+	if entry.suppressed == 1 {
+		time.AfterFunc(entry.start.Add(d.opts.Period).Sub(now), func() {
+			d.flush(key, entry)
+		})
+	}
+}
+
+// Writes a summary of suppressed messages for the finished period.
+func (d *deduplicator) flush(key dedupKey, entry *dedupEntry) {
+	d.mu.Lock()
+	suppressed := entry.suppressed
+	if d.entries[key] == entry {
+		delete(d.entries, key)
+	}
+	d.mu.Unlock()
+
+	d.log.WithLevel(key.level).
+		Uint64("suppressed", suppressed).
+		Dur("period", d.opts.Period).
+		Msgf("suppressed %d identical messages: %s", suppressed, key.msg)
+}
+
+// Removes finished entries without suppressed messages,
+// so unique messages don't pile up. Must be called with the mutex held.
+func (d *deduplicator) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.opts.Period {
+		return
+	}
+
+	d.lastSweep = now
+	for key, entry := range d.entries {
+		if entry.suppressed == 0 && now.Sub(entry.start) >= d.opts.Period {
+			delete(d.entries, key)
+		}
+	}
+}
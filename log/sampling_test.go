@@ -0,0 +1,115 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestWithSampling(t *testing.T) {
+	buf := &syncBuffer{}
+
+	log := WithSampling(New(buf, FormatJSON), SamplingOpts{
+		Burst:  2,
+		Period: time.Millisecond * 50,
+		Level:  zerolog.WarnLevel,
+	})
+
+	for range 5 {
+		log.Error().Msg("crashed")
+		log.Info().Msg("not deduplicated")
+	}
+	log.Error().Msg("another error")
+
+	output := buf.String()
+	assert.Equal(t, 2, strings.Count(output, `"message":"crashed"`))
+	assert.Equal(t, 5, strings.Count(output, "not deduplicated"))
+	assert.Equal(t, 1, strings.Count(output, "another error"))
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(buf.String(), `"suppressed":3`)
+	}, time.Second, time.Millisecond*10)
+	assert.Contains(t, buf.String(), "suppressed 3 identical messages: crashed")
+
+	log.Error().Msg("crashed")
+	assert.Equal(t, 3, strings.Count(buf.String(), `"message":"crashed"`))
+}
+
+func TestWithSampling_LevelVar(t *testing.T) {
+	buf := &syncBuffer{}
+
+	// Built as WithSampling does, to look into the deduplicator entries.
+	level := NewLevelVar(zerolog.WarnLevel)
+	base := WithLevelVar(New(buf, FormatJSON), level)
+	dedup := &deduplicator{
+		opts:    SamplingOpts{Burst: 1, Period: time.Millisecond * 20},
+		log:     base,
+		entries: make(map[dedupKey]*dedupEntry),
+	}
+	log := base.Hook(dedup)
+
+	for range 3 {
+		log.Info().Msg("filtered")
+		log.Warn().Msg("written")
+	}
+
+	// Messages discarded by the level aren't counted.
+	dedup.mu.Lock()
+	assert.Len(t, dedup.entries, 1)
+	assert.Contains(t, dedup.entries, dedupKey{level: zerolog.WarnLevel, msg: "written"})
+	dedup.mu.Unlock()
+
+	time.Sleep(time.Millisecond * 50)
+
+	output := buf.String()
+	assert.NotContains(t, output, "filtered")
+	assert.Equal(t, 1, strings.Count(output, `"message":"written"`))
+	assert.Contains(t, output, "suppressed 2 identical messages: written")
+}
+
+func TestWithSampling_Sampler(t *testing.T) {
+	buf := &syncBuffer{}
+
+	// Messages below the level don't use up the sampler budget.
+	log := WithSampling(WithLevelVar(New(buf, FormatJSON), NewLevelVar(zerolog.InfoLevel)), SamplingOpts{
+		Sampler: &zerolog.BasicSampler{N: 2},
+	})
+
+	for range 4 {
+		log.Debug().Msg("filtered")
+		log.Info().Msg("sampled")
+	}
+
+	output := buf.String()
+	assert.NotContains(t, output, "filtered")
+	assert.Equal(t, 2, strings.Count(output, `"message":"sampled"`))
+}
+
+func TestWithSampling_Disabled(t *testing.T) {
+	log := New(&bytes.Buffer{}, FormatJSON)
+	assert.Equal(t, log, WithSampling(log, SamplingOpts{}))
+}
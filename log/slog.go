@@ -0,0 +1,196 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+)
+
+// An `slog.Handler` that writes records through a `zerolog.Logger`.
+// Use `NewSlogHandler` or `Slog` to create one.
+type SlogHandler struct {
+	log    Logger
+	level  *LevelVar
+	prefix string
+}
+
+var _ slog.Handler = (*SlogHandler)(nil)
+
+// Returns an `slog.Handler` that writes records through the provided logger,
+// respecting its level, including a `LevelVar` one, and contextual fields.
+func NewSlogHandler(log Logger) *SlogHandler {
+	return &SlogHandler{log: log, level: levelVarOf(log)}
+}
+
+// Returns an `*slog.Logger` that writes records through the provided logger.
+func Slog(log Logger) *slog.Logger {
+	return slog.New(NewSlogHandler(log))
+}
+
+// Enabled implements slog.Handler.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	zlevel := ZerologLevel(level)
+	if h.level != nil && zlevel < h.level.Level() {
+		return false
+	}
+
+	return zlevel >= h.log.GetLevel() && zlevel >= zerolog.GlobalLevel()
+}
+
+// Handle implements slog.Handler.
+func (h *SlogHandler) Handle(_ context.Context, record slog.Record) error {
+	event := h.log.WithLevel(ZerologLevel(record.Level))
+	if event == nil {
+		return nil
+	}
+
+	record.Attrs(func(attr slog.Attr) bool {
+		appendAttr(event, h.prefix, attr)
+		return true
+	})
+
+	event.Msg(record.Message)
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	ctx := h.log.With()
+	for _, attr := range attrs {
+		ctx = appendContextAttr(ctx, h.prefix, attr)
+	}
+
+	return &SlogHandler{log: ctx.Logger(), level: h.level, prefix: h.prefix}
+}
+
+// WithGroup implements slog.Handler.
+// Attributes of a group are flattened using a dot-separated key prefix.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &SlogHandler{log: h.log, level: h.level, prefix: h.prefix + name + "."}
+}
+
+// Converts an `slog.Level` to the nearest `zerolog.Level`.
+func ZerologLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return zerolog.TraceLevel
+	case level < slog.LevelInfo:
+		return zerolog.DebugLevel
+	case level < slog.LevelWarn:
+		return zerolog.InfoLevel
+	case level < slog.LevelError:
+		return zerolog.WarnLevel
+	default:
+		return zerolog.ErrorLevel
+	}
+}
+
+// Converts a `zerolog.Level` to the nearest `slog.Level`.
+func SlogLevel(level zerolog.Level) slog.Level {
+	switch {
+	case level <= zerolog.TraceLevel:
+		return slog.LevelDebug - 4
+	case level == zerolog.DebugLevel:
+		return slog.LevelDebug
+	case level == zerolog.InfoLevel:
+		return slog.LevelInfo
+	case level == zerolog.WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+func appendAttr(event *zerolog.Event, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	key := prefix + attr.Key
+
+	switch attr.Value.Kind() {
+	case slog.KindGroup:
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix = key + "."
+		}
+
+		for _, child := range attr.Value.Group() {
+			appendAttr(event, groupPrefix, child)
+		}
+	case slog.KindString:
+		event.Str(key, attr.Value.String())
+	case slog.KindInt64:
+		event.Int64(key, attr.Value.Int64())
+	case slog.KindUint64:
+		event.Uint64(key, attr.Value.Uint64())
+	case slog.KindFloat64:
+		event.Float64(key, attr.Value.Float64())
+	case slog.KindBool:
+		event.Bool(key, attr.Value.Bool())
+	case slog.KindDuration:
+		event.Dur(key, attr.Value.Duration())
+	case slog.KindTime:
+		event.Time(key, attr.Value.Time())
+	default:
+		if err, ok := attr.Value.Any().(error); ok {
+			event.AnErr(key, err)
+			return
+		}
+
+		event.Interface(key, attr.Value.Any())
+	}
+}
+
+func appendContextAttr(ctx zerolog.Context, prefix string, attr slog.Attr) zerolog.Context {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return ctx
+	}
+
+	key := prefix + attr.Key
+
+	switch attr.Value.Kind() {
+	case slog.KindGroup:
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix = key + "."
+		}
+
+		for _, child := range attr.Value.Group() {
+			ctx = appendContextAttr(ctx, groupPrefix, child)
+		}
+
+		return ctx
+	case slog.KindString:
+		return ctx.Str(key, attr.Value.String())
+	case slog.KindInt64:
+		return ctx.Int64(key, attr.Value.Int64())
+	case slog.KindUint64:
+		return ctx.Uint64(key, attr.Value.Uint64())
+	case slog.KindFloat64:
+		return ctx.Float64(key, attr.Value.Float64())
+	case slog.KindBool:
+		return ctx.Bool(key, attr.Value.Bool())
+	case slog.KindDuration:
+		return ctx.Dur(key, attr.Value.Duration())
+	case slog.KindTime:
+		return ctx.Time(key, attr.Value.Time())
+	default:
+		if err, ok := attr.Value.Any().(error); ok {
+			return ctx.AnErr(key, err)
+		}
+
+		return ctx.Interface(key, attr.Value.Any())
+	}
+}
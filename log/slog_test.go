@@ -0,0 +1,52 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	logger := Slog(New(buf, FormatJSON).With().Str("app", t.Name()).Logger())
+	logger.Debug("skipped")
+	assert.Zero(t, buf.Len())
+
+	logger.With("service", "srv").WithGroup("req").Error(
+		"failed",
+		"id", 42,
+		slog.Group("peer", "addr", "127.0.0.1"),
+		"error", errors.New("boom"),
+	)
+
+	entry := map[string]any{}
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry)) {
+		assert.Equal(t, "error", entry["level"])
+		assert.Equal(t, "failed", entry["message"])
+		assert.Equal(t, t.Name(), entry["app"])
+		assert.Equal(t, "srv", entry["service"])
+		assert.Equal(t, float64(42), entry["req.id"])
+		assert.Equal(t, "127.0.0.1", entry["req.peer.addr"])
+		assert.Equal(t, "boom", entry["req.error"])
+	}
+}
+
+func TestLevels(t *testing.T) {
+	levels := []zerolog.Level{
+		zerolog.TraceLevel,
+		zerolog.DebugLevel,
+		zerolog.InfoLevel,
+		zerolog.WarnLevel,
+		zerolog.ErrorLevel,
+	}
+
+	for _, level := range levels {
+		assert.Equal(t, level, ZerologLevel(SlogLevel(level)))
+	}
+}
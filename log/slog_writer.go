@@ -0,0 +1,132 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// An `io.Writer` that decodes JSON log lines produced by a `zerolog.Logger`
+// and passes them to an `slog.Handler` as records.
+// Use it with `New(NewSlogWriter(handler), FormatJSON)` to bridge a zerolog
+// logger to any `slog.Handler`.
+type SlogWriter struct {
+	handler slog.Handler
+}
+
+var (
+	_ io.Writer           = (*SlogWriter)(nil)
+	_ zerolog.LevelWriter = (*SlogWriter)(nil)
+)
+
+// Returns a writer that passes zerolog JSON lines to the provided handler.
+func NewSlogWriter(handler slog.Handler) *SlogWriter {
+	return &SlogWriter{handler: handler}
+}
+
+// Write implements io.Writer.
+func (w *SlogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implements zerolog.LevelWriter.
+func (w *SlogWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	ctx := context.Background()
+
+	if level != zerolog.NoLevel && !w.handler.Enabled(ctx, SlogLevel(level)) {
+		return len(p), nil
+	}
+
+	record, err := decodeRecord(p)
+	if err != nil {
+		return 0, err
+	}
+
+	if level == zerolog.NoLevel && !w.handler.Enabled(ctx, record.Level) {
+		return len(p), nil
+	}
+
+	if err := w.handler.Handle(ctx, record); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Decodes a single zerolog JSON line into an `slog.Record`,
+// keeping the fields order.
+func decodeRecord(p []byte) (slog.Record, error) {
+	record := slog.Record{Time: time.Now(), Level: slog.LevelInfo}
+
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return record, errors.New("log line is not a JSON object")
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return record, errors.Wrap(err, "failed to decode log line")
+		}
+
+		key, _ := token.(string)
+
+		var value any
+		if err := decoder.Decode(&value); err != nil {
+			return record, errors.Wrapf(err, "failed to decode log line field '%s'", key)
+		}
+
+		switch key {
+		case zerolog.LevelFieldName:
+			if str, ok := value.(string); ok {
+				if level, err := zerolog.ParseLevel(str); err == nil {
+					record.Level = SlogLevel(level)
+				}
+			}
+		case zerolog.MessageFieldName:
+			record.Message, _ = value.(string)
+		case zerolog.TimestampFieldName:
+			if str, ok := value.(string); ok {
+				if ts, err := time.Parse(time.RFC3339Nano, str); err == nil {
+					record.Time = ts
+				}
+			}
+		default:
+			record.AddAttrs(jsonAttr(key, value))
+		}
+	}
+
+	return record, nil
+}
+
+func jsonAttr(key string, value any) slog.Attr {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return slog.Int64(key, i)
+		}
+
+		if f, err := v.Float64(); err == nil {
+			return slog.Float64(key, f)
+		}
+
+		return slog.String(key, v.String())
+	case map[string]any:
+		attrs := make([]any, 0, len(v))
+		for k, child := range v {
+			attrs = append(attrs, jsonAttr(k, child))
+		}
+
+		return slog.Group(key, attrs...)
+	default:
+		return slog.Any(key, v)
+	}
+}
@@ -0,0 +1,34 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	handler := slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelWarn})
+
+	log := New(NewSlogWriter(handler), FormatJSON).With().Str("app", t.Name()).Logger()
+	log.Info().Msg("skipped")
+	assert.Zero(t, buf.Len())
+
+	log.Error().Err(errors.New("boom")).Int("attempt", 3).Msg("failed")
+
+	entry := map[string]any{}
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry)) {
+		assert.Equal(t, "ERROR", entry["level"])
+		assert.Equal(t, "failed", entry["msg"])
+		assert.Equal(t, t.Name(), entry["app"])
+		assert.Equal(t, "boom", entry["error"])
+		assert.Equal(t, float64(3), entry["attempt"])
+	}
+
+	_, err := NewSlogWriter(handler).Write([]byte("not a json"))
+	assert.Error(t, err)
+}
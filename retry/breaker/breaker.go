@@ -0,0 +1,290 @@
+// Package breaker provides a circuit breaker that could be used either
+// directly inside `appetizer.Servicer.Run`, or composed with `retry.With`.
+package breaker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	DefaultFailureThreshold uint64 = 5
+	DefaultSuccessThreshold uint64 = 1
+	DefaultHalfOpenMaxCalls uint64 = 1
+	DefaultCoolDown                = time.Second * 30
+)
+
+// Returned by `Breaker.Do` when a call is rejected without running a target,
+// either because the breaker is open, or because the half-open state
+// already has `HalfOpenMaxCalls` probes in flight.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// Circuit breaker state.
+type State int32
+
+const (
+	// Calls are passing through, failures are being counted.
+	StateClosed State = iota
+
+	// Calls are rejected with `ErrOpen` until the cool-down period is over.
+	StateOpen
+
+	// A limited number of probe calls are passing through.
+	// A failed probe opens the breaker again, while `SuccessThreshold`
+	// successful probes in a row close it.
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Describes a single breaker state transition.
+type Event struct {
+	// Breaker name, see `Breaker.Name`.
+	Name string
+
+	From State
+	To   State
+
+	// The error that caused the transition, if any.
+	Err error
+
+	// Transition time.
+	At time.Time
+}
+
+// Circuit breaker. The zero value is a closed breaker with default options.
+// A breaker must not be copied after first use.
+type Breaker struct {
+	// Breaker name, passed as is to the `OnStateChange` events.
+	Name string
+
+	// A number of consecutive failures in the closed state
+	// required to open the breaker.
+	// If zero, the `DefaultFailureThreshold` will be used.
+	FailureThreshold uint64
+
+	// A number of consecutive successful probes in the half-open state
+	// required to close the breaker.
+	// If zero, the `DefaultSuccessThreshold` will be used.
+	SuccessThreshold uint64
+
+	// A maximum number of probes allowed to run concurrently
+	// in the half-open state.
+	// If zero, the `DefaultHalfOpenMaxCalls` will be used.
+	HalfOpenMaxCalls uint64
+
+	// How long the breaker stays open before allowing probes.
+	// If zero, the `DefaultCoolDown` will be used.
+	CoolDown time.Duration
+
+	// Decides whether a target error counts as a failure.
+	// If nil, every non-nil error counts except `context.Canceled`.
+	IsFailure func(err error) bool
+
+	// If set, called synchronously on every state transition.
+	// It must not call the breaker back.
+	OnStateChange func(event Event)
+
+	// Time source, mostly useful for tests. If nil, `time.Now` will be used.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	state     State
+	failures  uint64
+	successes uint64
+	probes    uint64
+	openedAt  time.Time
+
+	// Incremented on every transition, so results of calls acquired
+	// in a previous state aren't counted against the current one.
+	generation uint64
+}
+
+// Returns the current breaker state.
+// An open breaker with the cool-down period elapsed is reported as half-open.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh(b.now())
+	return b.state
+}
+
+// Resets the breaker to the closed state.
+func (b *Breaker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.transition(StateClosed, nil, b.now())
+}
+
+// Runs the `target` callable if the breaker allows it, returning its error.
+// If the call is rejected, `ErrOpen` is returned and `target` is not run.
+// If `target` panics, the call is counted as failed, and the panic goes on.
+func (b *Breaker) Do(ctx context.Context, target func(context.Context) error) error {
+	generation, err := b.acquire()
+	if err != nil {
+		return err
+	}
+
+	panicked := true
+	defer func() {
+		if panicked {
+			b.release(generation, errPanicked, true)
+		}
+	}()
+
+	err = target(ctx)
+	panicked = false
+
+	b.release(generation, err, false)
+	return err
+}
+
+// Returns `target` wrapped with `Do`, so it could be passed to `retry.With`.
+func (b *Breaker) Wrap(target func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		return b.Do(ctx, target)
+	}
+}
+
+// Returns the generation of the breaker state the call is allowed in.
+func (b *Breaker) acquire() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh(b.now())
+
+	switch b.state {
+	case StateOpen:
+		return 0, ErrOpen
+	case StateHalfOpen:
+		if b.probes >= orDefault(b.HalfOpenMaxCalls, DefaultHalfOpenMaxCalls) {
+			return 0, ErrOpen
+		}
+
+		b.probes++
+	}
+
+	return b.generation, nil
+}
+
+// A failure passed to `OnStateChange` when the breaker is opened by a panic.
+var errPanicked = errors.New("circuit breaker target panicked")
+
+// Counts the call result, unless the state has changed since the call was acquired.
+// A panicked call is a failure regardless of `IsFailure`.
+func (b *Breaker) release(generation uint64, err error, panicked bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	now := b.now()
+	failed := panicked || b.isFailure(err)
+
+	switch b.state {
+	case StateClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+
+		b.failures++
+		if b.failures >= orDefault(b.FailureThreshold, DefaultFailureThreshold) {
+			b.transition(StateOpen, err, now)
+		}
+	case StateHalfOpen:
+		if b.probes > 0 {
+			b.probes--
+		}
+
+		if failed {
+			b.transition(StateOpen, err, now)
+			return
+		}
+
+		b.successes++
+		if b.successes >= orDefault(b.SuccessThreshold, DefaultSuccessThreshold) {
+			b.transition(StateClosed, nil, now)
+		}
+	}
+}
+
+// Moves an open breaker to the half-open state if its cool-down has elapsed.
+// Must be called with the mutex held.
+func (b *Breaker) refresh(now time.Time) {
+	if b.state != StateOpen {
+		return
+	}
+
+	coolDown := b.CoolDown
+	if coolDown <= time.Duration(0) {
+		coolDown = DefaultCoolDown
+	}
+
+	if now.Sub(b.openedAt) >= coolDown {
+		b.transition(StateHalfOpen, nil, now)
+	}
+}
+
+// Must be called with the mutex held.
+func (b *Breaker) transition(to State, err error, now time.Time) {
+	from := b.state
+
+	b.state = to
+	b.generation++
+	b.failures = 0
+	b.successes = 0
+	b.probes = 0
+
+	if to == StateOpen {
+		b.openedAt = now
+	}
+
+	if from == to || b.OnStateChange == nil {
+		return
+	}
+
+	b.OnStateChange(Event{Name: b.Name, From: from, To: to, Err: err, At: now})
+}
+
+func (b *Breaker) isFailure(err error) bool {
+	if b.IsFailure != nil {
+		return b.IsFailure(err)
+	}
+
+	return err != nil && !errors.Is(err, context.Canceled)
+}
+
+func (b *Breaker) now() time.Time {
+	if b.Now != nil {
+		return b.Now()
+	}
+
+	return time.Now()
+}
+
+func orDefault(value, fallback uint64) uint64 {
+	if value == 0 {
+		return fallback
+	}
+
+	return value
+}
@@ -0,0 +1,169 @@
+package breaker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/homier/appetizer/retry"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestBreaker_Do(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	events := []Event{}
+
+	b := &Breaker{
+		Name:             t.Name(),
+		FailureThreshold: 2,
+		SuccessThreshold: 2,
+		CoolDown:         time.Second,
+		Now:              clock.Now,
+		OnStateChange: func(event Event) {
+			events = append(events, event)
+		},
+	}
+
+	errFailed := errors.New("failed")
+	fail := func(context.Context) error { return errFailed }
+	succeed := func(context.Context) error { return nil }
+
+	ctx := context.Background()
+
+	assert.ErrorIs(t, b.Do(ctx, fail), errFailed)
+	assert.Equal(t, StateClosed, b.State())
+
+	assert.ErrorIs(t, b.Do(ctx, fail), errFailed)
+	assert.Equal(t, StateOpen, b.State())
+
+	called := false
+	assert.ErrorIs(t, b.Do(ctx, func(context.Context) error {
+		called = true
+		return nil
+	}), ErrOpen)
+	assert.False(t, called)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	assert.ErrorIs(t, b.Do(ctx, fail), errFailed)
+	assert.Equal(t, StateOpen, b.State())
+
+	clock.Advance(time.Second)
+	assert.NoError(t, b.Do(ctx, succeed))
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	assert.NoError(t, b.Do(ctx, succeed))
+	assert.Equal(t, StateClosed, b.State())
+
+	transitions := make([][2]State, 0, len(events))
+	for _, event := range events {
+		assert.Equal(t, t.Name(), event.Name)
+		transitions = append(transitions, [2]State{event.From, event.To})
+	}
+
+	assert.Equal(t, [][2]State{
+		{StateClosed, StateOpen},
+		{StateOpen, StateHalfOpen},
+		{StateHalfOpen, StateOpen},
+		{StateOpen, StateHalfOpen},
+		{StateHalfOpen, StateClosed},
+	}, transitions)
+	assert.ErrorIs(t, events[0].Err, errFailed)
+}
+
+func TestBreaker_HalfOpenMaxCalls(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	b := &Breaker{FailureThreshold: 1, CoolDown: time.Second, Now: clock.Now}
+
+	ctx := context.Background()
+	assert.Error(t, b.Do(ctx, func(context.Context) error { return errors.New("failed") }))
+
+	clock.Advance(time.Second)
+
+	err := b.Do(ctx, func(context.Context) error {
+		return b.Do(ctx, func(context.Context) error { return nil })
+	})
+	assert.ErrorIs(t, err, ErrOpen)
+}
+
+func TestBreaker_StaleResults(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	b := &Breaker{FailureThreshold: 1, SuccessThreshold: 1, CoolDown: time.Second, Now: clock.Now}
+
+	ctx := context.Background()
+
+	// A slow call acquired while closed finishes once the breaker is half-open.
+	err := b.Do(ctx, func(context.Context) error {
+		assert.Error(t, b.Do(ctx, func(context.Context) error { return errors.New("failed") }))
+
+		clock.Advance(time.Second)
+		assert.Equal(t, StateHalfOpen, b.State())
+
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	assert.NoError(t, b.Do(ctx, func(context.Context) error { return nil }))
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreaker_Panic(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	b := &Breaker{FailureThreshold: 1, CoolDown: time.Second, Now: clock.Now}
+
+	ctx := context.Background()
+	panicking := func(context.Context) error { panic("boom") }
+
+	assert.PanicsWithValue(t, "boom", func() { _ = b.Do(ctx, panicking) })
+	assert.Equal(t, StateOpen, b.State())
+
+	// The half-open probe slot is released by a panicked probe.
+	clock.Advance(time.Second)
+	assert.PanicsWithValue(t, "boom", func() { _ = b.Do(ctx, panicking) })
+	assert.Equal(t, StateOpen, b.State())
+
+	clock.Advance(time.Second)
+	assert.NoError(t, b.Do(ctx, func(context.Context) error { return nil }))
+}
+
+func TestBreaker_IgnoresCancellation(t *testing.T) {
+	b := &Breaker{FailureThreshold: 1}
+
+	err := b.Do(context.Background(), func(context.Context) error { return context.Canceled })
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreaker_Wrap(t *testing.T) {
+	b := &Breaker{FailureThreshold: 1, CoolDown: time.Hour}
+
+	calls := 0
+	err := retry.With(context.Background(), b.Wrap(func(context.Context) error {
+		calls++
+		return errors.New("failed")
+	}), retry.Opts{
+		Opts:          &backoff.ZeroBackOff{},
+		CriticalError: ErrOpen,
+		MaxRetry:      5,
+	})
+
+	assert.ErrorIs(t, err, ErrOpen)
+	assert.Equal(t, 1, calls)
+}
@@ -2,6 +2,7 @@ package appetizer
 
 import (
 	"context"
+	"log/slog"
 
 	"github.com/homier/appetizer/log"
 	"github.com/homier/appetizer/retry"
@@ -19,6 +20,7 @@ type Servicer interface {
 	Init(log log.Logger) error
 
 	// Run your logic here.
+	// The service logger is available within `ctx`, see `log.FromContext`.
 	// If this method returns `nil`, a service is considered stopped,
 	// it won't be restarted event if the `Service.RestartEnabled` is true.
 	// If this method returns some kind of error, a service is considered failed,
@@ -42,4 +44,43 @@ type Service struct {
 	// a restart policy you need.
 	// NOTE: `RestartOpts.Opts` must be defined, otherwise service won't be restarted.
 	RestartOpts retry.Opts
+
+	// Service log level, one of "trace", "debug", "info", "warn", "error".
+	// If empty, the application log level is used.
+	// It could be changed at runtime using `App.SetLogLevel`.
+	LogLevel string
+
+	// Sampling and deduplication of the service log messages, useful to
+	// avoid flooding the output with identical errors of a crash-looping service.
+	// Disabled by default.
+	LogSampling log.SamplingOpts
+}
+
+// The same as `Servicer`, but receives an `*slog.Logger` on initialization.
+// Use `SlogService` to turn it into a `Servicer`.
+type SlogServicer interface {
+	// See `Servicer.Init`. The provided logger carries the "app" and
+	// "service" attributes and writes through the service logger,
+	// so the application log output, format and level apply.
+	Init(log *slog.Logger) error
+
+	// See `Servicer.Run`.
+	Run(ctx context.Context) error
+}
+
+// Returns a `Servicer` that runs the provided `SlogServicer`.
+func SlogService(servicer SlogServicer) Servicer {
+	return &slogService{servicer: servicer}
+}
+
+type slogService struct {
+	servicer SlogServicer
+}
+
+func (s *slogService) Init(logger log.Logger) error {
+	return s.servicer.Init(log.Slog(logger))
+}
+
+func (s *slogService) Run(ctx context.Context) error {
+	return s.servicer.Run(ctx)
 }
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net"
+	"net/http"
+	"runtime/pprof"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/homier/appetizer"
+	"github.com/homier/appetizer/log"
+)
+
+var (
+	DefaultAdminAddress = "127.0.0.1:9001"
+	DefaultAdminBaseURL = "/debug/"
+)
+
+// An application managed by the AdminServer.
+// It's implemented by *appetizer.App.
+type AdminApp interface {
+	LogLevelController
+
+	// Returns the current status of the application and its services.
+	Status() appetizer.AppStatus
+}
+
+// An admin HTTP server that implements appetizer.Servicer interface.
+// It serves debugging and management endpoints under `Config.BaseURL`,
+// which is "/debug/" by default:
+//
+//	pprof/       pprof index, profiles and named profiles, e.g. pprof/heap
+//	vars         expvar variables
+//	goroutines   stack traces of all goroutines
+//	build        build information of the binary, see `BuildInfoHandler`
+//	status       status of the application and its services, if `App` is set
+//	log/level    service log levels, if `App` is set, see `LogLevelHandler`
+//
+// The endpoints expose sensitive information, so the server should either
+// listen on a private address, or be protected by `Username` and `Password`,
+// or `AllowedNetworks`.
+type AdminServer struct {
+	// Server configuration.
+	// If `Address` is empty, the `DefaultAdminAddress` will be used.
+	// If `BaseURL` is empty, the `DefaultAdminBaseURL` will be used.
+	Config HTTPServerConfig
+
+	// An application to report status of and to control log levels of.
+	App AdminApp
+
+	// If both are set, requests must use HTTP basic authentication.
+	Username string
+	Password string
+
+	// If set, only clients from these networks are allowed,
+	// either in CIDR notation or as single IP addresses, see `ParseNetworks`.
+	// It's ignored for unix sockets, since clients have no IP address.
+	AllowedNetworks []string
+
+	// If set, the server accepts connections on this listener,
+	// and `Config.Network` and `Config.Address` are ignored.
+	Listener net.Listener
+
+	server HTTPServer
+}
+
+// Initializes AdminServer instance, validating its configuration.
+func (as *AdminServer) Init(log log.Logger) error {
+	if as.Config.Address == "" {
+		as.Config.Address = DefaultAdminAddress
+	}
+
+	if as.Config.BaseURL == "" || as.Config.BaseURL == "/" {
+		as.Config.BaseURL = DefaultAdminBaseURL
+	}
+
+	if (as.Username == "") != (as.Password == "") {
+		return errors.New("admin server requires both username and password")
+	}
+
+	middlewares := make([]Middleware, 0, 2)
+	if len(as.AllowedNetworks) > 0 && as.Config.Network != "unix" {
+		networks, err := ParseNetworks(as.AllowedNetworks...)
+		if err != nil {
+			return errors.Wrap(err, "invalid admin server allowed networks")
+		}
+
+		middlewares = append(middlewares, AllowNetworks(networks...))
+	}
+
+	if as.Username != "" {
+		middlewares = append(middlewares, BasicAuth(as.Username, as.Password, "Admin"))
+	}
+
+	as.server = HTTPServer{
+		Config:               as.Config,
+		Handlers:             as.handlers(),
+		Middlewares:          middlewares,
+		Listener:             as.Listener,
+		GracefulStopDisabled: !DefaultGracefulStopEnabled,
+	}
+
+	return as.server.Init(log)
+}
+
+// Returns the address the server is listening on,
+// or nil if it hasn't started listening yet. See `WaitCh`.
+func (as *AdminServer) Addr() net.Addr {
+	return as.server.Addr()
+}
+
+// Blocks until the server is listening and ready to serve requests,
+// or the provided context is either cancelled or timed out.
+// If context is cancelled/timed out, a context error is returning,
+// otherwise no error is returning.
+func (as *AdminServer) Wait(ctx context.Context) error {
+	return as.server.Wait(ctx)
+}
+
+// Returns a channel, that will be closed when the server is listening
+// and ready to serve requests.
+func (as *AdminServer) WaitCh() <-chan struct{} {
+	return as.server.WaitCh()
+}
+
+// Runs the server until its exit or the context cancellation.
+func (as *AdminServer) Run(ctx context.Context) error {
+	return as.server.Run(ctx)
+}
+
+func (as *AdminServer) handlers() []Handler {
+	handlers := []Handler{
+		{Path: "/pprof/", Handler: PprofMuxer(strings.TrimSuffix(as.Config.BaseURL, "/") + "/pprof/").ServeHTTP},
+		{Method: http.MethodGet, Path: "/vars", Handler: expvar.Handler().ServeHTTP},
+		{Method: http.MethodGet, Path: "/goroutines", Handler: goroutinesHandler},
+		{Method: http.MethodGet, Path: "/build", Handler: BuildInfoHandler(appetizer.ReadBuildInfo())},
+	}
+
+	if as.App != nil {
+		handlers = append(handlers,
+			Handler{Method: http.MethodGet, Path: "/status", Handler: statusHandler(as.App)},
+			Handler{Path: "/log/level", Handler: LogLevelHandler(as.App)},
+		)
+	}
+
+	return handlers
+}
+
+func goroutinesHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_ = pprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+func statusHandler(app AdminApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, app.Status())
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
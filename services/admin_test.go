@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/homier/appetizer"
+	"github.com/homier/appetizer/log"
+)
+
+// Runs the server until the test ends, returning its base URL.
+func runAdminServer(t *testing.T, as *AdminServer) string {
+	t.Helper()
+
+	require.NoError(t, as.Init(log.New(io.Discard, log.FormatJSON)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- as.Run(ctx) }()
+
+	t.Cleanup(func() {
+		cancel()
+		<-errCh
+	})
+
+	require.NoError(t, as.Wait(ctx))
+	return "http://" + as.Addr().String() + "/debug/"
+}
+
+func getAdmin(t *testing.T, url string, auth ...string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	if len(auth) == 2 {
+		req.SetBasicAuth(auth[0], auth[1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp.StatusCode, string(body)
+}
+
+func TestAdminServer(t *testing.T) {
+	app := &appetizer.App{
+		Name:     t.Name(),
+		Services: []appetizer.Service{{Name: "srv", LogLevel: "info"}},
+	}
+
+	url := runAdminServer(t, &AdminServer{Config: HTTPServerConfig{Address: "127.0.0.1:0"}, App: app})
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{path: "pprof/", body: "goroutine"},
+		{path: "pprof/heap?debug=1", body: "heap profile:"},
+		{path: "vars", body: `"memstats"`},
+		{path: "goroutines", body: "goroutine "},
+		{path: "build", body: `"go_version"`},
+		{path: "log/level?service=srv", body: `"level":"info"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			code, body := getAdmin(t, url+tt.path)
+
+			assert.Equal(t, http.StatusOK, code)
+			assert.Contains(t, body, tt.body)
+		})
+	}
+
+	t.Run("status", func(t *testing.T) {
+		code, body := getAdmin(t, url+"status")
+		assert.Equal(t, http.StatusOK, code)
+
+		var status appetizer.AppStatus
+		require.NoError(t, json.Unmarshal([]byte(body), &status))
+
+		assert.Equal(t, app.Status(), status)
+	})
+}
+
+func TestAdminServer_Auth(t *testing.T) {
+	t.Run("Basic auth", func(t *testing.T) {
+		url := runAdminServer(t, &AdminServer{
+			Config:   HTTPServerConfig{Address: "127.0.0.1:0"},
+			Username: "admin",
+			Password: "secret",
+		})
+
+		code, _ := getAdmin(t, url+"vars")
+		assert.Equal(t, http.StatusUnauthorized, code)
+
+		code, _ = getAdmin(t, url+"vars", "admin", "secret")
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("Allowed networks", func(t *testing.T) {
+		url := runAdminServer(t, &AdminServer{
+			Config:          HTTPServerConfig{Address: "127.0.0.1:0"},
+			AllowedNetworks: []string{"10.0.0.0/8"},
+		})
+
+		code, _ := getAdmin(t, url+"vars")
+		assert.Equal(t, http.StatusForbidden, code)
+
+		url = runAdminServer(t, &AdminServer{
+			Config:          HTTPServerConfig{Address: "127.0.0.1:0"},
+			AllowedNetworks: []string{"127.0.0.1"},
+		})
+
+		code, _ = getAdmin(t, url+"vars")
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("Invalid configuration", func(t *testing.T) {
+		as := &AdminServer{Username: "admin"}
+		assert.ErrorContains(t, as.Init(log.New(io.Discard, log.FormatJSON)), "both username and password")
+
+		as = &AdminServer{AllowedNetworks: []string{"nope"}}
+		assert.ErrorContains(t, as.Init(log.New(io.Discard, log.FormatJSON)), "invalid network 'nope'")
+	})
+}
@@ -0,0 +1,15 @@
+package services
+
+import (
+	"net/http"
+
+	"github.com/homier/appetizer"
+)
+
+// Returns a handler that responds with the build information as JSON,
+// e.g. `Handler{Method: "GET", Path: "/version", Handler: BuildInfoHandler(app.BuildInfo())}`.
+func BuildInfoHandler(info appetizer.BuildInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, info)
+	}
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/homier/appetizer"
+)
+
+func TestBuildInfoHandler(t *testing.T) {
+	info := appetizer.BuildInfo{
+		GoVersion:   "go1.22.5",
+		Main:        appetizer.Module{Path: "example.com/app", Version: "v1.2.3"},
+		VCSRevision: "c351d40",
+		VCSModified: true,
+	}
+
+	w := httptest.NewRecorder()
+	BuildInfoHandler(info).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var body appetizer.BuildInfo
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body)) {
+		assert.Equal(t, info, body)
+	}
+}
@@ -32,8 +32,11 @@ func ExampleHTTPServer() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go func() {
-		if err := app.Run(ctx); err != nil {
+	runCh := app.RunCh(ctx)
+	defer func() {
+		cancel()
+
+		if err := <-runCh; err != nil {
 			app.Log().Fatal().Err(err).Msg("Fatal error while running an application")
 		}
 	}()
@@ -45,6 +48,8 @@ func ExampleHTTPServer() {
 		app.Log().Fatal().Err(err).Msg("Could not query test HTTP server")
 	}
 
+	defer resp.Body.Close()
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		app.Log().Fatal().Err(err).
@@ -0,0 +1,468 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"github.com/homier/appetizer"
+	"github.com/homier/appetizer/log"
+)
+
+var DefaultGRPCAddress = "127.0.0.1:9090"
+
+// A function to register gRPC services on the server,
+// e.g. a generated `pb.RegisterGreeterServer(server, impl)` call.
+type GRPCRegistrar func(server *grpc.Server)
+
+// High level gRPC server configuration.
+// See google.golang.org/grpc.ServerOption for more.
+type GRPCServerConfig struct {
+	// A network to listen on: "tcp", "tcp4", "tcp6" or "unix".
+	// For "unix", `Address` is a socket file path.
+	Network string `json:"network" default:"tcp"`
+
+	// An address to listen on. Use port 0 to listen on an ephemeral port,
+	// the actual address is available through `GRPCServer.Addr`.
+	Address string `json:"address" default:"127.0.0.1:9090"`
+
+	// Maximum message sizes in bytes. If zero, gRPC defaults are used.
+	MaxRecvMsgSize int `json:"max_recv_msg_size" default:"0"`
+	MaxSendMsgSize int `json:"max_send_msg_size" default:"0"`
+
+	// Maximum number of concurrent streams per connection.
+	// If zero, gRPC default is used.
+	MaxConcurrentStreams uint32 `json:"max_concurrent_streams" default:"0"`
+
+	// How long a connection may be idle before the server pings it,
+	// and how long to wait for the ping ack. If zero, gRPC defaults are used.
+	KeepaliveTime    time.Duration `json:"keepalive_time" default:"0s"`
+	KeepaliveTimeout time.Duration `json:"keepalive_timeout" default:"0s"`
+
+	// Whether to serve TLS or not. Requires `TLSCertFile` and `TLSKeyFile`.
+	TLSEnabled bool `json:"tls_enabled" default:"false"`
+
+	// Paths to PEM encoded certificate and key files.
+	// The files are reloaded automatically when they change.
+	TLSCertFile string `json:"tls_cert_file"`
+	TLSKeyFile  string `json:"tls_key_file"`
+
+	// Path to a PEM encoded CA bundle. If defined, clients must present
+	// a certificate signed by one of these CAs (mTLS).
+	TLSClientCAFile string `json:"tls_client_ca_file"`
+
+	// How often to check the TLS files for changes,
+	// if file system notifications are unavailable.
+	TLSReloadInterval time.Duration `json:"tls_reload_interval" default:"10s"`
+
+	// Whether to register the standard gRPC health service or not.
+	// Every registered service is reported as serving while the server is running.
+	HealthEnabled bool `json:"health_enabled" default:"false"`
+
+	// Whether to register the gRPC reflection service or not.
+	ReflectionEnabled bool `json:"reflection_enabled" default:"false"`
+}
+
+// A gRPC server that implements appetizer.Servicer interface.
+// Every call gets a request logger within its context, see `log.FromContext`,
+// and is logged on completion, see `GRPCUnaryLogger` and `GRPCStreamLogger`.
+type GRPCServer struct {
+	// Server configuration
+	Config GRPCServerConfig
+
+	// Functions to register gRPC services on the server.
+	Registrars []GRPCRegistrar
+
+	// Additional server options. Applied after the ones built from `Config`.
+	ServerOptions []grpc.ServerOption
+
+	// Interceptors applied to every call, in order: the first one is
+	// the outermost. They run after the built-in logging interceptors,
+	// so the request logger is available.
+	UnaryInterceptors  []grpc.UnaryServerInterceptor
+	StreamInterceptors []grpc.StreamServerInterceptor
+
+	// If set, the server accepts connections on this listener,
+	// and `Config.Network` and `Config.Address` are ignored.
+	// A TCP or Unix listener is duplicated, so it could be served again
+	// on restart, see `HTTPServer.Listener`.
+	Listener net.Listener
+
+	// Whether to stop server gracefully or not.
+	// If disabled, the server is stopped immediately on context cancellation,
+	// dropping active calls.
+	GracefulStopEnabled bool
+
+	// If graceful stop is enabled, this timeout will be used
+	// to wait until its stop. If timeout has reached,
+	// remaining calls are cancelled.
+	// If zero, the `DefaultGracefulStopTimeout` will be used.
+	GracefulStopTimeout time.Duration
+
+	server *grpc.Server
+	health *health.Server
+	tls    *TLSReloader
+	addr   net.Addr
+	ready  appetizer.Waiter
+	served bool
+
+	log log.Logger
+	mu  sync.Mutex
+}
+
+// Initializes GRPCServer instance.
+// Building of a *grpc.Server instance and services registration happen here.
+func (gs *GRPCServer) Init(log log.Logger) error {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	gs.log = log
+	gs.served = false
+
+	if gs.Config.Address == "" {
+		gs.Config.Address = DefaultGRPCAddress
+	}
+
+	return gs.build()
+}
+
+// Builds the server and registers the services. A stopped *grpc.Server
+// can't be served again, so it's rebuilt before every run but the first one.
+func (gs *GRPCServer) build() error {
+	opts, err := gs.serverOptions()
+	if err != nil {
+		return err
+	}
+
+	gs.server = grpc.NewServer(opts...)
+	for _, register := range gs.Registrars {
+		register(gs.server)
+	}
+
+	gs.health = nil
+	if gs.Config.HealthEnabled {
+		gs.health = health.NewServer()
+		gs.health.Shutdown()
+
+		healthpb.RegisterHealthServer(gs.server, gs.health)
+	}
+
+	if gs.Config.ReflectionEnabled {
+		reflection.Register(gs.server)
+	}
+
+	return nil
+}
+
+func (gs *GRPCServer) serverOptions() ([]grpc.ServerOption, error) {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(append(
+			[]grpc.UnaryServerInterceptor{GRPCUnaryLogger(gs.log)}, gs.UnaryInterceptors...,
+		)...),
+		grpc.ChainStreamInterceptor(append(
+			[]grpc.StreamServerInterceptor{GRPCStreamLogger(gs.log)}, gs.StreamInterceptors...,
+		)...),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    gs.Config.KeepaliveTime,
+			Timeout: gs.Config.KeepaliveTimeout,
+		}),
+	}
+
+	if gs.Config.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(gs.Config.MaxRecvMsgSize))
+	}
+
+	if gs.Config.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(gs.Config.MaxSendMsgSize))
+	}
+
+	if gs.Config.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(gs.Config.MaxConcurrentStreams))
+	}
+
+	gs.tls = nil
+	if gs.Config.TLSEnabled {
+		reloader, err := NewTLSReloader(gs.Config.TLSCertFile, gs.Config.TLSKeyFile, gs.Config.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		// credentials.NewTLS adds "h2" to its own copy of the config only,
+		// while the per-client config of mTLS is a copy of this one.
+		config := &tls.Config{MinVersion: tls.VersionTLS12, NextProtos: []string{"h2"}}
+		reloader.Apply(config)
+
+		gs.tls = reloader
+		opts = append(opts, grpc.Creds(credentials.NewTLS(config)))
+	}
+
+	return append(opts, gs.ServerOptions...), nil
+}
+
+// Returns the address the server is listening on,
+// or nil if it hasn't started listening yet. See `WaitCh`.
+func (gs *GRPCServer) Addr() net.Addr {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	return gs.addr
+}
+
+// Returns the health service, or nil if it's disabled.
+// Use it to report the status of particular services.
+// The service is replaced when the server is restarted, so don't keep it.
+func (gs *GRPCServer) Health() *health.Server {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	return gs.health
+}
+
+// Blocks until the server is listening and ready to serve calls,
+// or the provided context is either cancelled or timed out.
+// If context is cancelled/timed out, a context error is returning,
+// otherwise no error is returning.
+func (gs *GRPCServer) Wait(ctx context.Context) error {
+	return gs.ready.Wait(ctx)
+}
+
+// Returns a channel, that will be closed when the server is listening
+// and ready to serve calls.
+func (gs *GRPCServer) WaitCh() <-chan struct{} {
+	return gs.ready.WaitCh()
+}
+
+// Runs the configured server in background and waits until
+// its exit or the context cancellation.
+// Returns either a server error or nil if the server was stopped.
+func (gs *GRPCServer) Run(ctx context.Context) error {
+	gs.mu.Lock()
+	if gs.served && gs.server != nil {
+		if err := gs.build(); err != nil {
+			gs.mu.Unlock()
+			return err
+		}
+	}
+
+	gs.served = gs.server != nil
+	server, reloader := gs.server, gs.tls
+	gs.mu.Unlock()
+
+	if server == nil {
+		return errors.Wrap(grpc.ErrServerStopped, "gRPC server is not initialized")
+	}
+
+	if reloader != nil {
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		go reloader.Watch(watchCtx, gs.Config.TLSReloadInterval, gs.log)
+	}
+
+	listener, err := listen(gs.Listener, gs.Config.Network, gs.Config.Address)
+	if err != nil {
+		return err
+	}
+
+	gs.mu.Lock()
+	gs.addr = listener.Addr()
+	gs.mu.Unlock()
+
+	gs.log.Info().Msgf("Listening on %s://%s", listener.Addr().Network(), listener.Addr())
+	gs.setServing(server, true)
+
+	runCh := make(chan error, 1)
+	go func() {
+		defer close(runCh)
+		defer gs.setServing(server, false)
+
+		runCh <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-runCh:
+		return errors.Wrap(err, "gRPC server failed")
+	case <-ctx.Done():
+		gs.stop(server, runCh)
+		return nil
+	}
+}
+
+// Stops the server gracefully if enabled, cancelling remaining calls
+// once the timeout is reached. Otherwise, the server is stopped immediately.
+func (gs *GRPCServer) stop(server *grpc.Server, runCh <-chan error) {
+	gs.setServing(server, false)
+
+	if !gs.GracefulStopEnabled {
+		gs.log.Info().Msg("Stopping")
+
+		server.Stop()
+		<-runCh
+
+		return
+	}
+
+	timeout := gs.GracefulStopTimeout
+	if timeout <= time.Duration(0) {
+		timeout = DefaultGracefulStopTimeout
+	}
+
+	gs.log.Info().Msg("Stopping gracefully")
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		server.GracefulStop()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-stopped:
+	case <-timer.C:
+		gs.log.Warn().Msgf("Graceful stop timed out after %s, cancelling remaining calls", timeout)
+		server.Stop()
+		<-stopped
+	}
+
+	<-runCh
+	gs.log.Info().Msg("Stopped")
+}
+
+// Marks the server and its registered services as (not) serving,
+// both for `Wait` and the health service.
+func (gs *GRPCServer) setServing(server *grpc.Server, serving bool) {
+	gs.ready.Set(serving)
+
+	gs.mu.Lock()
+	healthServer := gs.health
+	gs.mu.Unlock()
+
+	if healthServer == nil {
+		return
+	}
+
+	if !serving {
+		healthServer.Shutdown()
+		return
+	}
+
+	healthServer.Resume()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	for name := range server.GetServiceInfo() {
+		healthServer.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+}
+
+// Returns a unary interceptor that passes a per-call logger within
+// the call context, see `log.FromContext`, and logs every finished call
+// with its code and duration.
+// The logger has "grpc_method" and "request_id" fields. A request ID is
+// taken from the `RequestIDHeader` metadata or generated, and is sent back
+// in the response header.
+func GRPCUnaryLogger(logger log.Logger) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (any, error) {
+		start := time.Now()
+		ctx = grpcContextLogger(ctx, logger, info.FullMethod)
+
+		resp, err := handler(ctx, req)
+		logGRPCCall(ctx, start, err)
+
+		return resp, err
+	}
+}
+
+// Returns a stream interceptor that does the same as `GRPCUnaryLogger`.
+func GRPCStreamLogger(logger log.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := grpcContextLogger(stream.Context(), logger, info.FullMethod)
+
+		err := handler(srv, &contextServerStream{ServerStream: stream, ctx: ctx})
+		logGRPCCall(ctx, start, err)
+
+		return err
+	}
+}
+
+func grpcContextLogger(ctx context.Context, logger log.Logger, method string) context.Context {
+	requestID := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDHeader); len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, requestID))
+
+	callLog := logger.With().Str("request_id", requestID).Str("grpc_method", method).Logger()
+	return log.WithContext(ctx, callLog)
+}
+
+func logGRPCCall(ctx context.Context, start time.Time, err error) {
+	code := status.Code(err)
+	logger := log.FromContext(ctx)
+
+	event := logger.WithLevel(grpcCodeLevel(code)).
+		Str("code", code.String()).
+		Dur("duration", time.Since(start))
+
+	if p, ok := peer.FromContext(ctx); ok {
+		event = event.Str("peer", p.Addr.String())
+	}
+
+	if err != nil {
+		event = event.Err(err)
+	}
+
+	event.Msg("Call finished")
+}
+
+// Returns a log level for a call finished with `code`:
+// errors that point to a server problem are logged with the error level,
+// the ones that might need attention with the warn level, the rest with the info level.
+func grpcCodeLevel(code codes.Code) zerolog.Level {
+	switch code {
+	case codes.Unknown, codes.Unimplemented, codes.Internal, codes.DataLoss:
+		return zerolog.ErrorLevel
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.Aborted, codes.OutOfRange, codes.Unavailable:
+		return zerolog.WarnLevel
+	default:
+		return zerolog.InfoLevel
+	}
+}
+
+// A server stream with the replaced context.
+type contextServerStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/homier/appetizer/log"
+)
+
+func TestGRPCServer(t *testing.T) {
+	gs := &GRPCServer{
+		Config: GRPCServerConfig{
+			Address:           "127.0.0.1:0",
+			HealthEnabled:     true,
+			ReflectionEnabled: true,
+		},
+		GracefulStopEnabled: true,
+	}
+	require.NoError(t, gs.Init(log.New(io.Discard, log.FormatJSON)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- gs.Run(ctx) }()
+
+	require.NoError(t, gs.Wait(ctx))
+
+	conn, err := grpc.NewClient(gs.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := healthpb.NewHealthClient(conn)
+
+	for _, service := range []string{"", "grpc.health.v1.Health", "grpc.reflection.v1.ServerReflection"} {
+		var header metadata.MD
+
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service}, grpc.Header(&header))
+		require.NoError(t, err, service)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus(), service)
+		assert.NotEmpty(t, header.Get(RequestIDHeader))
+	}
+
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	cancel()
+	assert.NoError(t, <-errCh)
+	assert.False(t, gs.ready.Is(true))
+}
+
+func TestGRPCServer_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, cert := writeTestCert(t, dir, 1)
+
+	gs := &GRPCServer{
+		Config: GRPCServerConfig{
+			Address:         "127.0.0.1:0",
+			TLSEnabled:      true,
+			TLSCertFile:     certFile,
+			TLSKeyFile:      keyFile,
+			TLSClientCAFile: certFile,
+			HealthEnabled:   true,
+		},
+	}
+	require.NoError(t, gs.Init(log.New(io.Discard, log.FormatJSON)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- gs.Run(ctx) }()
+
+	require.NoError(t, gs.Wait(ctx))
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	clientCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+
+	check := func(config *tls.Config) error {
+		conn, err := grpc.NewClient(gs.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(config)))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+
+	assert.NoError(t, check(&tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{clientCert},
+		MinVersion:   tls.VersionTLS12,
+	}))
+
+	assert.Equal(t, codes.Unavailable, status.Code(check(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12})))
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+func TestGRPCUnaryLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	interceptor := GRPCUnaryLogger(log.New(buf, log.FormatJSON))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDHeader, "abc"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+
+	_, err := interceptor(ctx, nil, info, func(ctx context.Context, _ any) (any, error) {
+		logger := log.FromContext(ctx)
+		logger.Info().Msg("handled")
+
+		return nil, status.Error(codes.Internal, "boom")
+	})
+	require.Error(t, err)
+
+	assert.Contains(t, buf.String(), `"request_id":"abc","grpc_method":"/test.Service/Method"`)
+	assert.Contains(t, buf.String(), `"message":"handled"`)
+	assert.Contains(t, buf.String(), `"level":"error"`)
+	assert.Contains(t, buf.String(), `"code":"Internal"`)
+}
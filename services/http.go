@@ -2,16 +2,33 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	stdErrors "errors"
+	"io"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 
+	"github.com/homier/appetizer"
 	"github.com/homier/appetizer/log"
 )
 
 var (
+	// A header to take a request ID from, and to return it in.
+	RequestIDHeader = "X-Request-Id"
+
+	DefaultNetwork             = "tcp"
 	DefaultAddress             = "127.0.0.1:9000"
 	DefaultGracefulStopEnabled = true
 	DefaultGracefulStopTimeout = time.Second * 5
@@ -20,10 +37,49 @@ var (
 // An alias for net/http.Handler interface.
 type Muxer = http.Handler
 
-// A type for defining a pair of net/http.HandlerFunc and its URI path.
+// A type for defining a net/http.HandlerFunc route.
 type Handler struct {
-	Path    string
+	// HTTP method to match, e.g. "GET". If empty, any method matches,
+	// unless the method is a part of `Path`.
+	Method string
+
+	// URI path pattern, see net/http.ServeMux for the syntax.
+	// Path parameters like "/users/{id}" are available in a handler
+	// through *net/http.Request.PathValue.
+	// For backwards compatibility, the pattern may be prefixed with a method,
+	// e.g. "GET /hello". If `Method` is defined too, it takes precedence.
+	Path string
+
 	Handler http.HandlerFunc
+
+	// Middlewares applied to this handler only, see `Chain`.
+	Middlewares []Middleware
+}
+
+// Returns a net/http.ServeMux pattern of the handler with `baseURL`
+// prepended to its path, e.g. "GET /api/users/{id}".
+func (h Handler) Pattern(baseURL string) string {
+	method, path := "", h.Path
+	if before, after, found := strings.Cut(h.Path, " "); found {
+		method, path = before, strings.TrimLeft(after, " \t")
+	}
+
+	if h.Method != "" {
+		method = h.Method
+	}
+
+	// The path may start with a host, e.g. "example.com/hello".
+	host := ""
+	if index := strings.Index(path, "/"); index > 0 {
+		host, path = path[:index], path[index:]
+	}
+
+	path = host + strings.TrimSuffix(baseURL, "/") + path
+	if method == "" {
+		return path
+	}
+
+	return method + " " + path
 }
 
 // Function type used as *net/http.Server factory.
@@ -45,6 +101,7 @@ func DefaultServerFactory(config HTTPServerConfig, handlers []Handler, muxers ..
 }
 
 // Returns a muxer with applied handlers and children muxers.
+// The `uri` is used as a prefix for both handler patterns and muxers.
 func NewMuxer(uri string, handlers []Handler, muxers ...Muxer) *http.ServeMux {
 	if uri == "" {
 		uri = "/"
@@ -52,7 +109,7 @@ func NewMuxer(uri string, handlers []Handler, muxers ...Muxer) *http.ServeMux {
 
 	root := http.NewServeMux()
 	for _, handler := range handlers {
-		root.HandleFunc(handler.Path, handler.Handler)
+		root.Handle(handler.Pattern(uri), Chain(handler.Handler, handler.Middlewares...))
 	}
 
 	for _, muxer := range muxers {
@@ -66,9 +123,36 @@ func NewMuxer(uri string, handlers []Handler, muxers ...Muxer) *http.ServeMux {
 	return root
 }
 
+// Returns a handler that passes a per-request logger to `next` within
+// the request context, see `log.FromContext`.
+// It's a shortcut for the `RequestID` and `ContextLogger` middlewares.
+// If `next` is nil, the net/http.DefaultServeMux is used.
+func WithRequestLogger(logger log.Logger, next http.Handler) http.Handler {
+	if next == nil {
+		next = http.DefaultServeMux
+	}
+
+	return Chain(next, RequestID(), ContextLogger(logger))
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+
+	return hex.EncodeToString(id)
+}
+
 // High level server configuration.
 // See net/http.Server for more.
 type HTTPServerConfig struct {
+	// A network to listen on: "tcp", "tcp4", "tcp6" or "unix".
+	// For "unix", `Address` is a socket file path.
+	Network string `json:"network" default:"tcp"`
+
+	// An address to listen on. Use port 0 to listen on an ephemeral port,
+	// the actual address is available through `HTTPServer.Addr`.
 	Address string `json:"address" default:"127.0.0.1:9000"`
 	BaseURL string `json:"base_url" default:"/"`
 
@@ -79,11 +163,57 @@ type HTTPServerConfig struct {
 
 	MaxHeaderBytes int `json:"max_header_bytes" default:"0"`
 
-	// If enabled, you must configure TLS for server by yourself using
-	// a ServerFactory function
+	// Whether to serve TLS or not.
+	// If enabled, either `TLSCertFile` and `TLSKeyFile` must be defined,
+	// or a certificate must be configured in *net/http.Server.TLSConfig
+	// by a ServerFactory function.
 	TLSEnabled bool `json:"tls_enabled" default:"false"`
+
+	// Paths to PEM encoded certificate and key files.
+	// The files are reloaded automatically when they change.
+	TLSCertFile string `json:"tls_cert_file"`
+	TLSKeyFile  string `json:"tls_key_file"`
+
+	// Path to a PEM encoded CA bundle. If defined, clients must present
+	// a certificate signed by one of these CAs (mTLS).
+	TLSClientCAFile string `json:"tls_client_ca_file"`
+
+	// How often to check the TLS files for changes,
+	// if file system notifications are unavailable.
+	TLSReloadInterval time.Duration `json:"tls_reload_interval" default:"10s"`
+
+	// Whether to serve HTTP/2 over cleartext TCP (h2c) or not.
+	// Both prior knowledge and HTTP/1.1 Upgrade connections are accepted.
+	// It's ignored if TLS is enabled, since HTTP/2 is negotiated over TLS.
+	H2CEnabled bool `json:"h2c_enabled" default:"false"`
+
+	// Maximum number of concurrent HTTP/2 streams per connection.
+	// If zero, the net/http default is used.
+	HTTP2MaxConcurrentStreams uint32 `json:"http2_max_concurrent_streams" default:"0"`
+
+	// Whether to serve HTTP/3 over QUIC or not. Requires TLS.
+	// The server listens on the UDP port with the same address,
+	// and advertises HTTP/3 to TLS clients with the Alt-Svc header.
+	HTTP3Enabled bool `json:"http3_enabled" default:"false"`
 }
 
+// A phase of the HTTPServer shutdown.
+type ShutdownPhase string
+
+const (
+	// The server is not ready anymore, but still serving requests.
+	ShutdownPhaseDraining ShutdownPhase = "draining"
+
+	// The server is shutting down gracefully, waiting for active requests.
+	ShutdownPhaseShutdown ShutdownPhase = "shutdown"
+
+	// The server is closing all connections immediately.
+	ShutdownPhaseClose ShutdownPhase = "close"
+
+	// The server is stopped.
+	ShutdownPhaseStopped ShutdownPhase = "stopped"
+)
+
 // An http server that implements appetizer.Servicer interface.
 // Allows to predefine HTTP handlers and a list of muxers to include.
 type HTTPServer struct {
@@ -97,18 +227,44 @@ type HTTPServer struct {
 	// A list of children muxers that root muxer will include.
 	Muxers []Muxer
 
+	// Middlewares applied to every request, in order: the first one is
+	// the outermost. They run after the built-in `RequestID` and
+	// `ContextLogger` middlewares, so the request logger is available.
+	// See `AccessLog`, `Recover`, `Timeout`, `CORS`, `Gzip` and `MaxBodySize`.
+	Middlewares []Middleware
+
+	// If set, the server accepts connections on this listener,
+	// and `Config.Network` and `Config.Address` are ignored.
+	// Useful for systemd socket activation, see `systemd.Listeners`, or tests.
+	// The server serves a duplicate of a TCP or Unix listener and closes it
+	// on stop, so the listener could be served again on restart.
+	// Other listeners are closed on stop, and can't be served again.
+	Listener net.Listener
+
 	// A factory to return a *net/http.Server instance.
 	// If nil, the DefaultServerFactory will be used.
 	ServerFactory ServerFactory
 
-	// Whether to stop server gracefully or not.
-	GracefulStopEnabled bool
+	// Whether to close the server immediately on context cancellation,
+	// dropping active connections, instead of stopping it gracefully.
+	// The server is stopped gracefully by default.
+	GracefulStopDisabled bool
 
 	// If graceful stop is enabled, this timeout will be used
 	// to wait until its stop. If timeout has reached,
-	// server exits immediately.
+	// remaining connections are closed.
+	// If zero, the `DefaultGracefulStopTimeout` will be used.
 	GracefulStopTimeout time.Duration
 
+	// If graceful stop is enabled, the server keeps serving requests
+	// for this long after it stops being ready, before the actual shutdown.
+	// This gives load balancers time to notice the server is not ready,
+	// see `ReadyHandler`.
+	DrainDelay time.Duration
+
+	// If set, called on every shutdown phase.
+	OnShutdownPhase func(phase ShutdownPhase)
+
 	// Whether to enable pprof muxer or not.
 	PprofEnabled bool
 
@@ -116,6 +272,11 @@ type HTTPServer struct {
 	PprofURIPrefix string
 
 	server *http.Server
+	http3  *http3.Server
+	tls    *TLSReloader
+	addr   net.Addr
+	ready  appetizer.Waiter
+	served bool
 
 	log log.Logger
 	mu  sync.Mutex
@@ -128,7 +289,18 @@ func (hs *HTTPServer) Init(log log.Logger) error {
 	defer hs.mu.Unlock()
 
 	hs.log = log
+	hs.served = false
 
+	if hs.Config.Address == "" {
+		hs.Config.Address = DefaultAddress
+	}
+
+	return hs.build()
+}
+
+// Builds the servers. A closed *net/http.Server can't be served again,
+// so they're rebuilt before every run but the first one.
+func (hs *HTTPServer) build() error {
 	factory := DefaultServerFactory
 	if hs.ServerFactory != nil {
 		factory = hs.ServerFactory
@@ -139,29 +311,197 @@ func (hs *HTTPServer) Init(log log.Logger) error {
 		muxers = append([]Muxer{PprofMuxer(hs.PprofURIPrefix)}, muxers...)
 	}
 
-	if hs.Config.Address == "" {
-		hs.Config.Address = DefaultAddress
+	hs.server = factory(hs.Config, hs.Handlers, muxers...)
+
+	// The same as net/http.Server does for a nil handler,
+	// which the middlewares can't wrap.
+	handler := hs.server.Handler
+	if handler == nil {
+		handler = http.DefaultServeMux
 	}
 
-	hs.server = factory(hs.Config, hs.Handlers, muxers...)
+	hs.server.Handler = WithRequestLogger(hs.log, Chain(handler, hs.Middlewares...))
+
+	if err := hs.initTLS(); err != nil {
+		return err
+	}
+
+	if err := hs.initHTTP2(); err != nil {
+		return err
+	}
+
+	return hs.initHTTP3()
+}
+
+func (hs *HTTPServer) initTLS() error {
+	hs.tls = nil
+
+	if !hs.Config.TLSEnabled || hs.Config.TLSCertFile == "" {
+		return nil
+	}
+
+	reloader, err := NewTLSReloader(hs.Config.TLSCertFile, hs.Config.TLSKeyFile, hs.Config.TLSClientCAFile)
+	if err != nil {
+		return err
+	}
+
+	if hs.server.TLSConfig == nil {
+		hs.server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	// net/http adds the ALPN protocols to its own copy of the config only,
+	// while the per-client config of mTLS is a copy of this one.
+	if len(hs.server.TLSConfig.NextProtos) == 0 {
+		hs.server.TLSConfig.NextProtos = hs.nextProtos()
+	}
+
+	reloader.Apply(hs.server.TLSConfig)
+	hs.tls = reloader
+
+	return nil
+}
+
+// Returns the ALPN protocols of the server, as net/http chooses them:
+// a non-nil TLSNextProto without "h2" disables HTTP/2.
+func (hs *HTTPServer) nextProtos() []string {
+	if _, ok := hs.server.TLSNextProto["h2"]; hs.server.TLSNextProto != nil && !ok {
+		return []string{"http/1.1"}
+	}
+
+	return []string{"h2", "http/1.1"}
+}
+
+// Configures HTTP/2 tuning and h2c, if enabled.
+// Over TLS, net/http negotiates HTTP/2 by itself, so it's only
+// configured explicitly when there is something to tune.
+func (hs *HTTPServer) initHTTP2() error {
+	cleartext := hs.Config.H2CEnabled && !hs.Config.TLSEnabled
+	if !cleartext && hs.Config.HTTP2MaxConcurrentStreams == 0 {
+		return nil
+	}
+
+	h2 := &http2.Server{
+		MaxConcurrentStreams: hs.Config.HTTP2MaxConcurrentStreams,
+		IdleTimeout:          hs.server.IdleTimeout,
+	}
+
+	// Also makes HTTP/2 connections go away gracefully on shutdown.
+	if err := http2.ConfigureServer(hs.server, h2); err != nil {
+		return errors.Wrap(err, "failed to configure HTTP/2")
+	}
+
+	if cleartext {
+		hs.server.Handler = h2c.NewHandler(hs.server.Handler, h2)
+	}
+
+	return nil
+}
+
+// Creates an HTTP/3 server sharing the handler and the TLS configuration
+// with the main server, if enabled. TLS clients are told about HTTP/3
+// with the Alt-Svc header.
+func (hs *HTTPServer) initHTTP3() error {
+	hs.http3 = nil
+
+	if !hs.Config.HTTP3Enabled {
+		return nil
+	}
+
+	if !hs.Config.TLSEnabled || hs.server.TLSConfig == nil {
+		return errors.New("HTTP/3 requires TLS to be enabled")
+	}
+
+	if hs.Config.Network == "unix" {
+		return errors.New("HTTP/3 is not supported over Unix sockets")
+	}
+
+	server := &http3.Server{
+		Handler:        hs.server.Handler,
+		TLSConfig:      hs.server.TLSConfig,
+		MaxHeaderBytes: hs.server.MaxHeaderBytes,
+	}
+
+	next := hs.server.Handler
+	hs.server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			// Fails only if the server isn't listening yet.
+			_ = server.SetQUICHeaders(w.Header())
+		}
+
+		next.ServeHTTP(w, r)
+	})
+
+	hs.http3 = server
 	return nil
 }
 
+// Returns the address the server is listening on,
+// or nil if it hasn't started listening yet. See `WaitCh`.
+func (hs *HTTPServer) Addr() net.Addr {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	return hs.addr
+}
+
+// Blocks until the server is listening and ready to serve requests,
+// or the provided context is either cancelled or timed out.
+// If context is cancelled/timed out, a context error is returning,
+// otherwise no error is returning.
+func (hs *HTTPServer) Wait(ctx context.Context) error {
+	return hs.ready.Wait(ctx)
+}
+
+// Returns a channel, that will be closed when the server is listening
+// and ready to serve requests.
+func (hs *HTTPServer) WaitCh() <-chan struct{} {
+	return hs.ready.WaitCh()
+}
+
+// Returns a readiness probe handler. It responds with 200 OK while
+// the server is ready, and with 503 Service Unavailable once it's stopping.
+// Register it in `Handlers` to let load balancers drain the server.
+func (hs *HTTPServer) ReadyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if hs.ready.Is(true) {
+			_, _ = io.WriteString(w, "ready\n")
+			return
+		}
+
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+	}
+}
+
 // Runs the configured server in background and waits until
 // its exit or the context cancellation.
 // Returns either a server error, or a context error, or a server stop error.
 func (hs *HTTPServer) Run(ctx context.Context) error {
+	hs.mu.Lock()
+	if hs.served && hs.server != nil {
+		if err := hs.build(); err != nil {
+			hs.mu.Unlock()
+			return err
+		}
+	}
+
+	hs.served = hs.server != nil
+	reloader := hs.tls
+	hs.mu.Unlock()
+
+	if reloader != nil {
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		go reloader.Watch(watchCtx, hs.Config.TLSReloadInterval, hs.log)
+	}
+
 	runCh := hs.runServer()
 
 	select {
 	case err := <-runCh:
 		return err
 	case <-ctx.Done():
-		if !hs.GracefulStopEnabled {
-			return hs.gracefulStop()
-		}
-
-		return hs.forceStop()
+		return hs.stop(runCh)
 	}
 }
 
@@ -169,7 +509,7 @@ func (hs *HTTPServer) runServer() <-chan error {
 	ch := make(chan error, 1)
 
 	hs.mu.Lock()
-	server := hs.server
+	server, h3 := hs.server, hs.http3
 	hs.mu.Unlock()
 
 	if server == nil {
@@ -179,35 +519,181 @@ func (hs *HTTPServer) runServer() <-chan error {
 		return ch
 	}
 
-	go func(server *http.Server) {
+	listener, err := listen(hs.Listener, hs.Config.Network, server.Addr)
+	if err != nil {
+		ch <- err
+		close(ch)
+
+		return ch
+	}
+
+	serves := []func() error{func() error {
+		if hs.Config.TLSEnabled {
+			return server.ServeTLS(listener, "", "")
+		}
+
+		return server.Serve(listener)
+	}}
+
+	if h3 != nil {
+		conn, err := net.ListenPacket("udp", listener.Addr().String())
+		if err != nil {
+			_ = listener.Close()
+
+			ch <- errors.Wrapf(err, "failed to listen on udp://%s", listener.Addr())
+			close(ch)
+
+			return ch
+		}
+
+		serves = append(serves, func() error {
+			defer conn.Close()
+
+			return h3.Serve(conn)
+		})
+
+		hs.log.Info().Msgf("Listening on %s://%s", conn.LocalAddr().Network(), conn.LocalAddr())
+	}
+
+	hs.mu.Lock()
+	hs.addr = listener.Addr()
+	hs.mu.Unlock()
+
+	hs.log.Info().Msgf("Listening on %s://%s", listener.Addr().Network(), listener.Addr())
+	hs.ready.Set(true)
+
+	go func() {
 		defer close(ch)
+		defer hs.ready.Set(false)
 
-		ch <- server.ListenAndServe()
-	}(server)
+		errCh := make(chan error, len(serves))
+		for _, serve := range serves {
+			go func(serve func() error) { errCh <- serve() }(serve)
+		}
+
+		// If one of the servers fails, the others are closed,
+		// so the failure is reported once all of them are done.
+		err := http.ErrServerClosed
+		for range serves {
+			serveErr := <-errCh
+			if errors.Is(serveErr, http.ErrServerClosed) || err != http.ErrServerClosed {
+				continue
+			}
+
+			err = serveErr
+			_ = closeServers(server, h3)
+		}
+
+		ch <- err
+	}()
 
-	hs.log.Info().Msgf("Listening on %s", hs.server.Addr)
 	return ch
 }
 
-func (hs *HTTPServer) gracefulStop() error {
+// Returns `listener` if defined, otherwise listens on `network` and `address`.
+// If `network` is empty, the `DefaultNetwork` will be used.
+func listen(listener net.Listener, network, address string) (net.Listener, error) {
+	if listener != nil {
+		return duplicateListener(listener), nil
+	}
+
+	if network == "" {
+		network = DefaultNetwork
+	}
+
+	if network == "unix" {
+		removeStaleSocket(address)
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen on %s://%s", network, address)
+	}
+
+	return listener, nil
+}
+
+// Returns a listener sharing the socket of `listener`, so closing it
+// doesn't close `listener`. The listener itself is returned if it can't
+// be duplicated, e.g. if it's not a TCP or Unix listener.
+func duplicateListener(listener net.Listener) net.Listener {
+	filer, ok := listener.(interface{ File() (*os.File, error) })
+	if !ok {
+		return listener
+	}
+
+	file, err := filer.File()
+	if err != nil {
+		return listener
+	}
+	defer file.Close()
+
+	duplicate, err := net.FileListener(file)
+	if err != nil {
+		return listener
+	}
+
+	return duplicate
+}
+
+// Removes a Unix socket file left by a previous process, if any.
+func removeStaleSocket(path string) {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		_ = os.Remove(path)
+	}
+}
+
+// Stops the server going through the shutdown phases.
+// If graceful stop is enabled, the server stops being ready, waits for
+// the drain delay, then shuts down gracefully within the timeout,
+// and is closed if the timeout is reached.
+// Otherwise, the server is closed immediately.
+func (hs *HTTPServer) stop(runCh <-chan error) error {
+	hs.ready.Set(false)
+
+	hs.mu.Lock()
+	server, h3 := hs.server, hs.http3
+	hs.mu.Unlock()
+
+	if hs.GracefulStopDisabled {
+		return hs.close(server, h3, runCh)
+	}
+
+	if hs.DrainDelay > time.Duration(0) {
+		hs.enterPhase(ShutdownPhaseDraining)
+		server.SetKeepAlivesEnabled(false)
+
+		timer := time.NewTimer(hs.DrainDelay)
+
+		select {
+		case <-timer.C:
+		case err := <-runCh:
+			timer.Stop()
+			hs.enterPhase(ShutdownPhaseStopped)
+
+			return err
+		}
+	}
+
 	timeout := hs.GracefulStopTimeout
 	if timeout <= time.Duration(0) {
 		timeout = DefaultGracefulStopTimeout
 	}
 
+	hs.enterPhase(ShutdownPhaseShutdown)
+
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	hs.mu.Lock()
-	if hs.server == nil {
-		hs.mu.Unlock()
-		return nil
+	err := shutdownServers(ctx, server, h3)
+	if errors.Is(err, context.DeadlineExceeded) {
+		hs.log.Warn().Msgf("Graceful shutdown timed out after %s, closing remaining connections", timeout)
+		return hs.close(server, h3, runCh)
 	}
 
-	server := hs.server
-	hs.mu.Unlock()
+	<-runCh
+	hs.enterPhase(ShutdownPhaseStopped)
 
-	err := server.Shutdown(ctx)
 	if err == nil || errors.Is(err, http.ErrServerClosed) {
 		return nil
 	}
@@ -215,20 +701,54 @@ func (hs *HTTPServer) gracefulStop() error {
 	return errors.Wrap(err, "failed gracefully stop HTTP server")
 }
 
-func (hs *HTTPServer) forceStop() error {
-	hs.mu.Lock()
-	if hs.server == nil {
-		hs.mu.Unlock()
-		return nil
-	}
+func (hs *HTTPServer) close(server *http.Server, h3 *http3.Server, runCh <-chan error) error {
+	hs.enterPhase(ShutdownPhaseClose)
 
-	server := hs.server
-	hs.mu.Unlock()
+	err := closeServers(server, h3)
+	<-runCh
+
+	hs.enterPhase(ShutdownPhaseStopped)
 
-	err := server.Close()
 	if err == nil || errors.Is(err, http.ErrServerClosed) {
 		return nil
 	}
 
 	return errors.Wrap(err, "failed stop HTTP server")
 }
+
+// Shuts down the HTTP and, if defined, HTTP/3 servers concurrently.
+func shutdownServers(ctx context.Context, server *http.Server, h3 *http3.Server) error {
+	if h3 == nil {
+		return server.Shutdown(ctx)
+	}
+
+	h3ErrCh := make(chan error, 1)
+	go func() { h3ErrCh <- h3.Shutdown(ctx) }()
+
+	return stdErrors.Join(server.Shutdown(ctx), <-h3ErrCh)
+}
+
+func closeServers(server *http.Server, h3 *http3.Server) error {
+	if h3 == nil {
+		return server.Close()
+	}
+
+	return stdErrors.Join(server.Close(), h3.Close())
+}
+
+func (hs *HTTPServer) enterPhase(phase ShutdownPhase) {
+	switch phase {
+	case ShutdownPhaseDraining:
+		hs.log.Info().Str("phase", string(phase)).Msgf("Draining for %s before shutdown", hs.DrainDelay)
+	case ShutdownPhaseShutdown:
+		hs.log.Info().Str("phase", string(phase)).Msg("Shutting down gracefully")
+	case ShutdownPhaseClose:
+		hs.log.Info().Str("phase", string(phase)).Msg("Closing")
+	case ShutdownPhaseStopped:
+		hs.log.Info().Str("phase", string(phase)).Msg("Stopped")
+	}
+
+	if hs.OnShutdownPhase != nil {
+		hs.OnShutdownPhase(phase)
+	}
+}
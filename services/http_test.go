@@ -0,0 +1,452 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+
+	"github.com/homier/appetizer"
+	"github.com/homier/appetizer/log"
+)
+
+func TestWithRequestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	handler := WithRequestLogger(log.New(buf, log.FormatJSON), http.HandlerFunc(
+		func(_ http.ResponseWriter, r *http.Request) {
+			logger := log.FromContext(r.Context())
+			logger.Info().Msg("handled")
+		},
+	))
+
+	t.Run("Provided request ID", func(t *testing.T) {
+		buf.Reset()
+
+		r := httptest.NewRequest(http.MethodGet, "/hello", nil)
+		r.Header.Set(RequestIDHeader, "42")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, "42", w.Header().Get(RequestIDHeader))
+		assert.Contains(t, buf.String(), `"request_id":"42"`)
+		assert.Contains(t, buf.String(), `"method":"GET"`)
+		assert.Contains(t, buf.String(), `"path":"/hello"`)
+	})
+
+	t.Run("Generated request ID", func(t *testing.T) {
+		buf.Reset()
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello", nil))
+
+		requestID := w.Header().Get(RequestIDHeader)
+		assert.Len(t, requestID, 32)
+		assert.Contains(t, buf.String(), `"request_id":"`+requestID+`"`)
+	})
+}
+
+// Runs the server until the test ends, returning its run error channel.
+func runHTTPServer(t *testing.T, hs *HTTPServer) (context.CancelFunc, <-chan error) {
+	t.Helper()
+
+	hs.Handlers = append(hs.Handlers, Handler{
+		Path: "/hello",
+		Handler: func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = io.WriteString(w, "world")
+		},
+	})
+	require.NoError(t, hs.Init(log.New(io.Discard, log.FormatJSON)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	t.Cleanup(cancel)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- hs.Run(ctx) }()
+
+	require.NoError(t, hs.Wait(ctx))
+	return cancel, errCh
+}
+
+func assertHello(t *testing.T, client *http.Client, url string) {
+	t.Helper()
+
+	assertBody(t, client, url, "world")
+}
+
+func TestHTTPServer_Listen(t *testing.T) {
+	t.Run("Ephemeral port", func(t *testing.T) {
+		hs := &HTTPServer{Config: HTTPServerConfig{Address: "127.0.0.1:0"}}
+		assert.Nil(t, hs.Addr())
+
+		cancel, errCh := runHTTPServer(t, hs)
+
+		addr, ok := hs.Addr().(*net.TCPAddr)
+		require.True(t, ok)
+		assert.NotZero(t, addr.Port)
+
+		assertHello(t, http.DefaultClient, "http://"+addr.String()+"/hello")
+
+		cancel()
+		assert.NoError(t, <-errCh)
+	})
+
+	t.Run("Unix socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "http.sock")
+
+		hs := &HTTPServer{Config: HTTPServerConfig{Network: "unix", Address: path}}
+		cancel, errCh := runHTTPServer(t, hs)
+
+		assert.Equal(t, path, hs.Addr().String())
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		}}
+		assertHello(t, client, "http://unix/hello")
+
+		cancel()
+		assert.NoError(t, <-errCh)
+	})
+
+	t.Run("Listener", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		hs := &HTTPServer{Listener: listener}
+		cancel, errCh := runHTTPServer(t, hs)
+
+		assert.Equal(t, listener.Addr(), hs.Addr())
+		assertHello(t, http.DefaultClient, "http://"+listener.Addr().String()+"/hello")
+
+		cancel()
+		assert.NoError(t, <-errCh)
+	})
+}
+
+func TestHandler_Pattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler Handler
+		baseURL string
+		want    string
+	}{
+		{name: "path only", handler: Handler{Path: "/hello"}, baseURL: "/", want: "/hello"},
+		{name: "method field", handler: Handler{Method: "GET", Path: "/hello"}, baseURL: "/", want: "GET /hello"},
+		{name: "method in path", handler: Handler{Path: "POST /hello"}, baseURL: "/", want: "POST /hello"},
+		{name: "method override", handler: Handler{Method: "PUT", Path: "POST /hello"}, baseURL: "", want: "PUT /hello"},
+		{name: "base URL", handler: Handler{Method: "GET", Path: "/users/{id}"}, baseURL: "/api/", want: "GET /api/users/{id}"},
+		{name: "base URL without slash", handler: Handler{Path: "/hello"}, baseURL: "/api", want: "/api/hello"},
+		{name: "host", handler: Handler{Path: "GET example.com/hello"}, baseURL: "/api", want: "GET example.com/api/hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.handler.Pattern(tt.baseURL))
+		})
+	}
+}
+
+func TestNewMuxer(t *testing.T) {
+	muxer := NewMuxer("/api", []Handler{{
+		Method: http.MethodGet,
+		Path:   "/users/{id}",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, r.PathValue("id"))
+		},
+	}})
+
+	w := httptest.NewRecorder()
+	muxer.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/users/42", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "42", w.Body.String())
+
+	w = httptest.NewRecorder()
+	muxer.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/users/42", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w = httptest.NewRecorder()
+	muxer.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHTTPServer_Stop(t *testing.T) {
+	tests := []struct {
+		name   string
+		server *HTTPServer
+
+		// A phase to let the in-flight request finish on.
+		releaseOn ShutdownPhase
+		phases    []ShutdownPhase
+		finished  bool
+	}{
+		{
+			name:      "graceful with drain",
+			server:    &HTTPServer{DrainDelay: time.Millisecond * 50},
+			releaseOn: ShutdownPhaseShutdown,
+			phases: []ShutdownPhase{
+				ShutdownPhaseDraining, ShutdownPhaseShutdown, ShutdownPhaseStopped,
+			},
+			finished: true,
+		},
+		{
+			name:   "graceful timed out",
+			server: &HTTPServer{GracefulStopTimeout: time.Millisecond * 50},
+			phases: []ShutdownPhase{
+				ShutdownPhaseShutdown, ShutdownPhaseClose, ShutdownPhaseStopped,
+			},
+		},
+		{
+			name:   "forced",
+			server: &HTTPServer{GracefulStopDisabled: true},
+			phases: []ShutdownPhase{ShutdownPhaseClose, ShutdownPhaseStopped},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			phases := make(chan ShutdownPhase, 4)
+
+			hs := tt.server
+			hs.Config.Address = "127.0.0.1:0"
+			hs.OnShutdownPhase = func(phase ShutdownPhase) {
+				phases <- phase
+
+				if phase == ShutdownPhaseDraining {
+					resp, err := http.Get("http://" + hs.Addr().String() + "/ready")
+					if assert.NoError(t, err) {
+						resp.Body.Close()
+						assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+					}
+				}
+
+				if phase == tt.releaseOn {
+					close(release)
+				}
+			}
+			hs.Handlers = []Handler{
+				{Path: "/ready", Handler: hs.ReadyHandler()},
+				{Path: "/slow", Handler: func(w http.ResponseWriter, _ *http.Request) {
+					close(started)
+					select {
+					case <-release:
+					case <-time.After(time.Second):
+					}
+
+					_, _ = io.WriteString(w, "done")
+				}},
+			}
+
+			cancel, errCh := runHTTPServer(t, hs)
+			url := "http://" + hs.Addr().String()
+
+			type result struct {
+				body string
+				err  error
+			}
+
+			resultCh := make(chan result, 1)
+			go func() {
+				resp, err := http.Get(url + "/slow")
+				if err != nil {
+					resultCh <- result{err: err}
+					return
+				}
+				defer resp.Body.Close()
+
+				body, err := io.ReadAll(resp.Body)
+				resultCh <- result{body: string(body), err: err}
+			}()
+
+			<-started
+			cancel()
+
+			assert.NoError(t, <-errCh)
+			assert.True(t, hs.ready.Is(false))
+
+			res := <-resultCh
+			if tt.finished {
+				assert.NoError(t, res.err)
+				assert.Equal(t, "done", res.body)
+			} else {
+				assert.Error(t, res.err)
+			}
+
+			close(phases)
+
+			got := []ShutdownPhase{}
+			for phase := range phases {
+				got = append(got, phase)
+			}
+			assert.Equal(t, tt.phases, got)
+		})
+	}
+}
+
+func TestHTTPServer_ReadyHandler(t *testing.T) {
+	hs := &HTTPServer{}
+
+	w := httptest.NewRecorder()
+	hs.ReadyHandler()(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	hs.ready.Set(true)
+
+	w = httptest.NewRecorder()
+	hs.ReadyHandler()(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestHTTPServer_H2C(t *testing.T) {
+	hs := &HTTPServer{
+		Config: HTTPServerConfig{
+			Address:                   "127.0.0.1:0",
+			H2CEnabled:                true,
+			HTTP2MaxConcurrentStreams: 10,
+		},
+		Handlers: []Handler{{Path: "/proto", Handler: writeProto}},
+	}
+	cancel, errCh := runHTTPServer(t, hs)
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+
+	assertBody(t, client, "http://"+hs.Addr().String()+"/proto", "HTTP/2.0")
+	assertBody(t, http.DefaultClient, "http://"+hs.Addr().String()+"/proto", "HTTP/1.1")
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+func TestHTTPServer_HTTP3(t *testing.T) {
+	t.Run("TLS required", func(t *testing.T) {
+		hs := &HTTPServer{Config: HTTPServerConfig{Address: "127.0.0.1:0", HTTP3Enabled: true}}
+		assert.Error(t, hs.Init(log.New(io.Discard, log.FormatJSON)))
+	})
+
+	certFile, keyFile, cert := writeTestCert(t, t.TempDir(), 1)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	hs := &HTTPServer{
+		Config: HTTPServerConfig{
+			Address:      "127.0.0.1:0",
+			TLSEnabled:   true,
+			TLSCertFile:  certFile,
+			TLSKeyFile:   keyFile,
+			HTTP3Enabled: true,
+		},
+		Handlers: []Handler{{Path: "/proto", Handler: writeProto}},
+	}
+	cancel, errCh := runHTTPServer(t, hs)
+
+	url := "https://" + hs.Addr().String() + "/proto"
+	tlsConfig := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+
+	resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}).Get(url)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	port := strconv.Itoa(hs.Addr().(*net.TCPAddr).Port)
+	assert.Contains(t, resp.Header.Get("Alt-Svc"), `h3=":`+port+`"`)
+
+	transport := &http3.Transport{TLSClientConfig: tlsConfig}
+	defer transport.Close()
+
+	assertBody(t, &http.Client{Transport: transport}, url, "HTTP/3.0")
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+func writeProto(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.WriteString(w, r.Proto)
+}
+
+func assertBody(t *testing.T, client *http.Client, url, expected string) {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(body))
+}
+
+func TestHTTPServer_Restart(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	servers := map[string]*HTTPServer{
+		"address":  {Config: HTTPServerConfig{Address: "127.0.0.1:0"}},
+		"listener": {Listener: listener},
+	}
+
+	services := make([]appetizer.Service, 0, len(servers))
+	for name, hs := range servers {
+		hs.Handlers = []Handler{{
+			Path: "/hello",
+			Handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = io.WriteString(w, "world")
+			},
+		}}
+
+		services = append(services, appetizer.Service{Name: name, Servicer: hs})
+	}
+
+	app := &appetizer.App{Name: t.Name(), Services: services, LogOutput: io.Discard}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*4)
+	defer cancel()
+
+	runCh := app.RunCh(ctx)
+
+	for name, hs := range servers {
+		require.NoError(t, hs.Wait(ctx))
+		assertHello(t, http.DefaultClient, "http://"+hs.Addr().String()+"/hello")
+
+		require.NoError(t, app.RestartService(name))
+
+		assert.Eventually(t, func() bool {
+			for _, status := range app.Status().Services {
+				if status.Name == name {
+					return status.Restarts == 1 && status.State == appetizer.ServiceStateRunning && hs.ready.Is(true)
+				}
+			}
+
+			return false
+		}, time.Second, time.Millisecond*10, name)
+
+		assertHello(t, http.DefaultClient, "http://"+hs.Addr().String()+"/hello")
+	}
+
+	assert.Equal(t, listener.Addr().String(), servers["listener"].Addr().String())
+
+	cancel()
+	assert.NoError(t, <-runCh)
+}
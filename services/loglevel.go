@@ -0,0 +1,81 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/homier/appetizer"
+)
+
+// Allows to read and change service log levels at runtime.
+// It's implemented by *appetizer.App.
+type LogLevelController interface {
+	// Returns the current log level of the service,
+	// or an error wrapping `appetizer.ErrServiceNotFound`.
+	LogLevel(service string) (zerolog.Level, error)
+
+	// Changes the log level of the service.
+	SetLogLevel(service string, level zerolog.Level) error
+}
+
+// A body of the LogLevelHandler responses.
+type LogLevelResponse struct {
+	Service string `json:"service"`
+	Level   string `json:"level"`
+}
+
+// Returns a handler to read and change service log levels at runtime.
+// A service name is taken from the "service" query parameter.
+//
+// GET returns the current level of the service.
+// PUT or POST changes it to the value of the "level" query parameter,
+// e.g. `PUT /log/level?service=http_server&level=debug`.
+func LogLevelHandler(controller LogLevelController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		service := r.URL.Query().Get("service")
+		if service == "" {
+			http.Error(w, "'service' query parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut, http.MethodPost:
+			level, err := zerolog.ParseLevel(r.URL.Query().Get("level"))
+			if err != nil || level == zerolog.NoLevel {
+				http.Error(w, "'level' query parameter is invalid", http.StatusBadRequest)
+				return
+			}
+
+			if err := controller.SetLogLevel(service, level); err != nil {
+				writeLogLevelError(w, err)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		level, err := controller.LogLevel(service)
+		if err != nil {
+			writeLogLevelError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(LogLevelResponse{Service: service, Level: level.String()})
+	}
+}
+
+func writeLogLevelError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	if errors.Is(err, appetizer.ErrServiceNotFound) {
+		code = http.StatusNotFound
+	}
+
+	http.Error(w, err.Error(), code)
+}
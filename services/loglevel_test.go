@@ -0,0 +1,54 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/homier/appetizer"
+)
+
+func TestLogLevelHandler(t *testing.T) {
+	app := &appetizer.App{
+		Name:     t.Name(),
+		Services: []appetizer.Service{{Name: "srv", LogLevel: "warn"}},
+	}
+
+	handler := LogLevelHandler(app)
+
+	tests := []struct {
+		name   string
+		method string
+		query  string
+		code   int
+		level  string
+	}{
+		{name: "get", method: http.MethodGet, query: "service=srv", code: http.StatusOK, level: "warn"},
+		{name: "set", method: http.MethodPut, query: "service=srv&level=debug", code: http.StatusOK, level: "debug"},
+		{name: "get after set", method: http.MethodGet, query: "service=srv", code: http.StatusOK, level: "debug"},
+		{name: "no service", method: http.MethodGet, code: http.StatusBadRequest},
+		{name: "unknown service", method: http.MethodGet, query: "service=unknown", code: http.StatusNotFound},
+		{name: "invalid level", method: http.MethodPut, query: "service=srv&level=loud", code: http.StatusBadRequest},
+		{name: "invalid method", method: http.MethodDelete, query: "service=srv", code: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(tt.method, "/log/level?"+tt.query, nil))
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			resp := LogLevelResponse{}
+			if assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp)) {
+				assert.Equal(t, LogLevelResponse{Service: "srv", Level: tt.level}, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,595 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/homier/appetizer/log"
+)
+
+// A function that wraps a handler with some cross-cutting behaviour.
+type Middleware = func(http.Handler) http.Handler
+
+type requestIDKey struct{}
+
+// Wraps `handler` with `middlewares`. The first middleware is the outermost one,
+// so it's the first to see a request.
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// Returns a middleware that assigns an ID to every request.
+// A request ID is taken from the `RequestIDHeader` header or generated,
+// and is written to the same response header.
+// Use `RequestIDFromContext` to get it.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" {
+				requestID = newRequestID()
+			}
+
+			w.Header().Set(RequestIDHeader, requestID)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID)))
+		})
+	}
+}
+
+// Returns a request ID assigned by the `RequestID` middleware, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// Returns a middleware that passes a per-request logger within the request
+// context, see `log.FromContext`. The logger is derived from `logger`
+// and has "method", "path" and, if assigned, "request_id" fields.
+func ContextLogger(logger log.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := logger.With()
+			if requestID := RequestIDFromContext(r.Context()); requestID != "" {
+				ctx = ctx.Str("request_id", requestID)
+			}
+
+			requestLog := ctx.Str("method", r.Method).Str("path", r.URL.Path).Logger()
+			next.ServeHTTP(w, r.WithContext(log.WithContext(r.Context(), requestLog)))
+		})
+	}
+}
+
+// Returns a middleware that logs every served request with its status,
+// response size and duration, using the request logger, see `ContextLogger`.
+// Server errors are logged with the error level, the rest with the info level.
+func AccessLog() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(recorder, r)
+
+			logger := log.FromContext(r.Context())
+			event := logger.Info()
+			if recorder.status >= http.StatusInternalServerError {
+				event = logger.Error()
+			}
+
+			event.
+				Int("status", recorder.status).
+				Int64("size", recorder.size).
+				Dur("duration", time.Since(start)).
+				Str("remote_addr", r.RemoteAddr).
+				Msg("Request served")
+		})
+	}
+}
+
+// Returns a middleware that recovers handler panics, logging them
+// with a stack trace and responding with 500 Internal Server Error.
+// The net/http.ErrAbortHandler panics are passed through.
+func Recover() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				logger := log.FromContext(r.Context())
+				logger.Error().
+					Interface("panic", recovered).
+					Bytes("stack", debug.Stack()).
+					Msg("Request handler panicked")
+
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Returns a middleware that limits the request handling time,
+// responding with 503 Service Unavailable on timeout.
+// The request context is cancelled on timeout, and the handler writes fail
+// with net/http.ErrHandlerTimeout. Like net/http.TimeoutHandler, the response
+// is buffered, unless the handler flushes it: then it's streamed,
+// so it can't be replaced on timeout, and is cut short instead.
+func Timeout(timeout time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{ResponseWriter: w, header: make(http.Header)}
+
+			done := make(chan struct{})
+			panicCh := make(chan any, 1)
+
+			go func() {
+				defer func() {
+					if recovered := recover(); recovered != nil {
+						panicCh <- recovered
+					}
+				}()
+
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case recovered := <-panicCh:
+				panic(recovered)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+
+				tw.commit()
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+
+				tw.timedOut = true
+				if !tw.committed {
+					http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				}
+			}
+		})
+	}
+}
+
+// Returns a middleware that limits the request body size.
+// Reading more than `size` bytes fails, see net/http.MaxBytesReader.
+func MaxBodySize(size int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, size)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Returns a middleware that requires HTTP basic authentication
+// with the provided credentials, responding with 401 Unauthorized otherwise.
+func BasicAuth(username, password, realm string) Middleware {
+	if realm == "" {
+		realm = "Restricted"
+	}
+
+	challenge := `Basic realm="` + strings.ReplaceAll(realm, `"`, `'`) + `", charset="UTF-8"`
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+
+			// Both are compared anyway, so the response time doesn't tell which one is wrong.
+			userMatch := subtle.ConstantTimeCompare([]byte(user), []byte(username))
+			passMatch := subtle.ConstantTimeCompare([]byte(pass), []byte(password))
+
+			if !ok || userMatch&passMatch != 1 {
+				w.Header().Set("WWW-Authenticate", challenge)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Returns a middleware that only allows requests from the `networks`,
+// responding with 403 Forbidden otherwise. A client address is taken
+// from net/http.Request.RemoteAddr, so proxy headers are not trusted.
+// See `ParseNetworks`.
+func AllowNetworks(networks ...*net.IPNet) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+
+			ip := net.ParseIP(host)
+			for _, network := range networks {
+				if ip != nil && network.Contains(ip) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		})
+	}
+}
+
+// Parses networks in CIDR notation, e.g. "10.0.0.0/8".
+// A single IP address is treated as a network of that address only.
+func ParseNetworks(cidrs ...string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		if ip := net.ParseIP(cidr); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid network '%s'", cidr)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// CORS middleware options.
+type CORSOpts struct {
+	// Allowed origins. Use "*" to allow any origin. The "*" is ignored
+	// if credentials are allowed, so credentials aren't shared with any site.
+	AllowedOrigins []string
+
+	// Allowed methods. If empty, GET, HEAD and POST are allowed.
+	AllowedMethods []string
+
+	// Allowed request headers.
+	AllowedHeaders []string
+
+	// Response headers exposed to the client.
+	ExposedHeaders []string
+
+	// Whether to allow credentials or not.
+	AllowCredentials bool
+
+	// How long the preflight response could be cached. If zero, it's not sent.
+	MaxAge time.Duration
+}
+
+// Returns a middleware that handles CORS requests, including preflight ones.
+// Requests from disallowed origins are passed through without CORS headers.
+func CORS(opts CORSOpts) Middleware {
+	methods := opts.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+
+	allowedMethods := strings.Join(methods, ", ")
+	allowedHeaders := strings.Join(opts.AllowedHeaders, ", ")
+	exposedHeaders := strings.Join(opts.ExposedHeaders, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header := w.Header()
+			header.Add("Vary", "Origin")
+
+			allowedOrigin, ok := opts.allowedOrigin(origin)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header.Set("Access-Control-Allow-Origin", allowedOrigin)
+			if opts.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+				if exposedHeaders != "" {
+					header.Set("Access-Control-Expose-Headers", exposedHeaders)
+				}
+
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header.Set("Access-Control-Allow-Methods", allowedMethods)
+			if allowedHeaders != "" {
+				header.Set("Access-Control-Allow-Headers", allowedHeaders)
+			}
+
+			if opts.MaxAge > time.Duration(0) {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+func (opts CORSOpts) allowedOrigin(origin string) (string, bool) {
+	for _, allowed := range opts.AllowedOrigins {
+		if allowed == "*" && !opts.AllowCredentials {
+			return "*", true
+		}
+
+		if allowed == origin {
+			return origin, true
+		}
+	}
+
+	return "", false
+}
+
+// Returns a middleware that compresses responses with gzip
+// if the client accepts it. If `level` is zero, gzip.DefaultCompression is used.
+// Responses that already have a Content-Encoding are passed as is.
+func Gzip(level int) Middleware {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			if r.Method == http.MethodHead || !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gw := &gzipResponseWriter{ResponseWriter: w, level: level}
+			defer gw.Close()
+
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.TrimSpace(name) == "gzip" && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Records a response status and size.
+type responseRecorder struct {
+	http.ResponseWriter
+
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if !rr.wroteHeader {
+		rr.status = status
+		rr.wroteHeader = true
+	}
+
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(p []byte) (int, error) {
+	rr.wroteHeader = true
+
+	n, err := rr.ResponseWriter.Write(p)
+	rr.size += int64(n)
+
+	return n, err
+}
+
+func (rr *responseRecorder) Flush() {
+	rr.wroteHeader = true
+
+	_ = http.NewResponseController(rr.ResponseWriter).Flush()
+}
+
+// Allows net/http.ResponseController to reach the underlying writer.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+type gzipResponseWriter struct {
+	http.ResponseWriter
+
+	level       int
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (gw *gzipResponseWriter) WriteHeader(status int) {
+	if gw.wroteHeader {
+		return
+	}
+
+	gw.wroteHeader = true
+
+	header := gw.Header()
+	if header.Get("Content-Encoding") == "" && status != http.StatusNoContent &&
+		status != http.StatusNotModified && status >= http.StatusOK {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+
+		gz, err := gzip.NewWriterLevel(gw.ResponseWriter, gw.level)
+		if err != nil {
+			gz = gzip.NewWriter(gw.ResponseWriter)
+		}
+
+		gw.gz = gz
+	}
+
+	gw.ResponseWriter.WriteHeader(status)
+}
+
+func (gw *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !gw.wroteHeader {
+		if gw.Header().Get("Content-Type") == "" {
+			gw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+
+		gw.WriteHeader(http.StatusOK)
+	}
+
+	if gw.gz == nil {
+		return gw.ResponseWriter.Write(p)
+	}
+
+	return gw.gz.Write(p)
+}
+
+func (gw *gzipResponseWriter) Flush() {
+	if gw.gz != nil {
+		_ = gw.gz.Flush()
+	}
+
+	_ = http.NewResponseController(gw.ResponseWriter).Flush()
+}
+
+func (gw *gzipResponseWriter) Close() {
+	if gw.gz != nil {
+		_ = gw.gz.Close()
+	}
+}
+
+// Allows net/http.ResponseController to reach the underlying writer.
+func (gw *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+// Buffers a response until the handler returns or flushes it.
+// The fields are guarded by the mutex, since the handler outlives the request on timeout.
+type timeoutWriter struct {
+	http.ResponseWriter
+
+	header http.Header
+	buf    bytes.Buffer
+	status int
+
+	mu          sync.Mutex
+	wroteHeader bool
+	committed   bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+
+	tw.wroteHeader = true
+	tw.status = status
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	tw.wroteHeader = true
+	if tw.committed {
+		return tw.ResponseWriter.Write(p)
+	}
+
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return
+	}
+
+	tw.wroteHeader = true
+	tw.commit()
+
+	_ = http.NewResponseController(tw.ResponseWriter).Flush()
+}
+
+// Allows net/http.ResponseController to reach the underlying writer.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// Writes the buffered response. Must be called with the mutex held.
+func (tw *timeoutWriter) commit() {
+	if tw.committed {
+		return
+	}
+
+	tw.committed = true
+
+	header := tw.ResponseWriter.Header()
+	for key, values := range tw.header {
+		header[key] = values
+	}
+
+	status := tw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	tw.ResponseWriter.WriteHeader(status)
+	_, _ = tw.ResponseWriter.Write(tw.buf.Bytes())
+	tw.buf.Reset()
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/homier/appetizer/log"
+)
+
+func TestChain(t *testing.T) {
+	order := []string{}
+	middleware := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	handler := Chain(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		order = append(order, "handler")
+	}), middleware("first"), middleware("second"))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []string{"first", "second", "handler"}, order)
+}
+
+func TestAccessLogAndRecover(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	handler := Chain(
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("boom") }),
+		RequestID(), ContextLogger(log.New(buf, log.FormatJSON)), AccessLog(), Recover(),
+	)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, buf.String(), `"panic":"boom"`)
+	assert.Contains(t, buf.String(), `"status":500`)
+	assert.Contains(t, buf.String(), `"request_id":"`+w.Header().Get(RequestIDHeader)+`"`)
+}
+
+func TestTimeout(t *testing.T) {
+	handler := Timeout(time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "partial")
+		<-r.Context().Done()
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotContains(t, w.Body.String(), "partial")
+}
+
+func TestMiddlewares_Flush(t *testing.T) {
+	received := make(chan struct{})
+
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "first\n")
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		select {
+		case <-received:
+		case <-r.Context().Done():
+			return
+		}
+
+		_, _ = io.WriteString(w, "second\n")
+	}), ContextLogger(log.New(io.Discard, log.FormatJSON)), AccessLog(), Gzip(0), Timeout(time.Second))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.True(t, resp.Uncompressed)
+
+	// The first line is received while the handler is still running.
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", line)
+
+	close(received)
+
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", line)
+}
+
+func TestMaxBodySize(t *testing.T) {
+	handler := MaxBodySize(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("too large")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("ok")))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCORS(t *testing.T) {
+	handler := CORS(CORSOpts{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPut},
+		AllowedHeaders: []string{"Content-Type"},
+		MaxAge:         time.Minute,
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	t.Run("Preflight", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodOptions, "/", nil)
+		r.Header.Set("Origin", "https://example.com")
+		r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET, PUT", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "60", w.Header().Get("Access-Control-Max-Age"))
+	})
+
+	t.Run("Disallowed origin", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Origin", "https://evil.com")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusTeapot, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Any origin with credentials", func(t *testing.T) {
+		handler := CORS(CORSOpts{
+			AllowedOrigins:   []string{"*", "https://example.com"},
+			AllowCredentials: true,
+		})(http.NotFoundHandler())
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Origin", "https://evil.com")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+
+		r.Header.Set("Origin", "https://example.com")
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+}
+
+func TestGzip(t *testing.T) {
+	handler := Gzip(0)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "<html>hello</html>")
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "deflate, gzip")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+
+	gz, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "<html>hello</html>", string(body))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "<html>hello</html>", w.Body.String())
+}
+
+func TestHTTPServer_Middlewares(t *testing.T) {
+	header := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Middleware", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	hs := &HTTPServer{
+		Config:      HTTPServerConfig{Address: "127.0.0.1:0"},
+		Middlewares: []Middleware{header("server")},
+		Handlers: []Handler{{
+			Path:        "/route",
+			Handler:     func(http.ResponseWriter, *http.Request) {},
+			Middlewares: []Middleware{header("route")},
+		}},
+	}
+
+	cancel, errCh := runHTTPServer(t, hs)
+
+	resp, err := http.Get("http://" + hs.Addr().String() + "/route")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, []string{"server", "route"}, resp.Header.Values("X-Middleware"))
+	assert.NotEmpty(t, resp.Header.Get(RequestIDHeader))
+
+	cancel()
+	assert.NoError(t, <-errCh)
+
+	t.Run("Nil handler", func(t *testing.T) {
+		hs := &HTTPServer{
+			Config:      HTTPServerConfig{Address: "127.0.0.1:0"},
+			Middlewares: []Middleware{header("server")},
+			ServerFactory: func(config HTTPServerConfig, _ []Handler, _ ...Muxer) *http.Server {
+				return &http.Server{Addr: config.Address, ReadHeaderTimeout: time.Second}
+			},
+		}
+
+		cancel, errCh := runHTTPServer(t, hs)
+
+		// Served by the net/http.DefaultServeMux.
+		resp, err := http.Get("http://" + hs.Addr().String() + "/appetizer-missing")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, []string{"server"}, resp.Header.Values("X-Middleware"))
+
+		cancel()
+		assert.NoError(t, <-errCh)
+	})
+}
+
+func TestBasicAuth(t *testing.T) {
+	handler := BasicAuth("admin", "secret", "")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		noAuth   bool
+		code     int
+	}{
+		{name: "valid", username: "admin", password: "secret", code: http.StatusOK},
+		{name: "invalid password", username: "admin", password: "wrong", code: http.StatusUnauthorized},
+		{name: "invalid username", username: "root", password: "secret", code: http.StatusUnauthorized},
+		{name: "no credentials", noAuth: true, code: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if !tt.noAuth {
+				r.SetBasicAuth(tt.username, tt.password)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == http.StatusUnauthorized {
+				assert.Equal(t, `Basic realm="Restricted", charset="UTF-8"`, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAllowNetworks(t *testing.T) {
+	networks, err := ParseNetworks("10.0.0.0/8", "192.168.1.1", "::1")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	handler := AllowNetworks(networks...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		remoteAddr string
+		code       int
+	}{
+		{remoteAddr: "10.1.2.3:1234", code: http.StatusOK},
+		{remoteAddr: "192.168.1.1:1234", code: http.StatusOK},
+		{remoteAddr: "[::1]:1234", code: http.StatusOK},
+		{remoteAddr: "192.168.1.2:1234", code: http.StatusForbidden},
+		{remoteAddr: "127.0.0.1:1234", code: http.StatusForbidden},
+		{remoteAddr: "@", code: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remoteAddr, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+
+	_, err = ParseNetworks("10.0.0.0/33")
+	assert.ErrorContains(t, err, "invalid network '10.0.0.0/33'")
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	"github.com/homier/appetizer/log"
+)
+
+// A task run by the `Periodic` and `Cron` servicers.
+// The service logger is available within `ctx`, see `log.FromContext`.
+type Task func(ctx context.Context) error
+
+// Task scheduling options.
+type TaskOpts struct {
+	// If positive, every run is delayed by a random duration up to this one,
+	// so several instances don't run the task at the same moment.
+	Jitter time.Duration
+
+	// Whether to run the task immediately on start or not.
+	RunOnStart bool
+
+	// If positive, the context of every run is cancelled after this timeout.
+	Timeout time.Duration
+
+	// Whether a run may start while the previous one is still in progress.
+	// If disabled, such runs are skipped.
+	AllowOverlap bool
+
+	// If positive, the servicer fails after this many consecutive task failures,
+	// so the failure is subject to the service restart policy.
+	// If zero, task errors are only logged.
+	FailureThreshold uint64
+}
+
+// A servicer that runs a task every `Interval`.
+type Periodic struct {
+	Task     Task
+	Interval time.Duration
+	Opts     TaskOpts
+
+	log log.Logger
+}
+
+// Initializes Periodic instance, validating its configuration.
+func (p *Periodic) Init(log log.Logger) error {
+	p.log = log
+
+	if p.Task == nil {
+		return errors.New("periodic task is not defined")
+	}
+
+	if p.Interval <= time.Duration(0) {
+		return errors.New("periodic task interval must be positive")
+	}
+
+	return nil
+}
+
+// Runs the task every interval until the context is done,
+// waiting for the active runs to finish before returning.
+func (p *Periodic) Run(ctx context.Context) error {
+	return runTask(ctx, p.log, p.Task, p.Opts, func(now time.Time) time.Time {
+		return now.Add(p.Interval)
+	})
+}
+
+// A servicer that runs a task on a cron schedule.
+type Cron struct {
+	Task Task
+
+	// A cron expression with five fields: minute, hour, day of month,
+	// month and day of week, e.g. "*/5 * * * *".
+	// Descriptors like "@hourly" or "@every 1h30m" are supported too.
+	// See github.com/robfig/cron for the syntax.
+	Schedule string
+
+	// A time zone to evaluate the schedule in. If nil, the local one is used.
+	Location *time.Location
+
+	Opts TaskOpts
+
+	schedule cron.Schedule
+	log      log.Logger
+}
+
+// Initializes Cron instance, parsing its schedule.
+func (c *Cron) Init(log log.Logger) error {
+	c.log = log
+
+	if c.Task == nil {
+		return errors.New("cron task is not defined")
+	}
+
+	schedule, err := cron.ParseStandard(c.Schedule)
+	if err != nil {
+		return errors.Wrapf(err, "invalid cron schedule '%s'", c.Schedule)
+	}
+
+	c.schedule = schedule
+	return nil
+}
+
+// Runs the task on schedule until the context is done,
+// waiting for the active runs to finish before returning.
+func (c *Cron) Run(ctx context.Context) error {
+	if c.schedule == nil {
+		return errors.New("cron task is not initialized")
+	}
+
+	return runTask(ctx, c.log, c.Task, c.Opts, func(now time.Time) time.Time {
+		if c.Location != nil {
+			now = now.In(c.Location)
+		}
+
+		return c.schedule.Next(now)
+	})
+}
+
+// Runs `task` at the moments returned by `next` until the context is done,
+// or the failure threshold is reached.
+func runTask(
+	ctx context.Context, log log.Logger, task Task, opts TaskOpts, next func(now time.Time) time.Time,
+) error {
+	runner := &taskRunner{task: task, opts: opts, log: log, failed: make(chan error, 1)}
+	defer runner.wg.Wait()
+
+	if opts.RunOnStart {
+		runner.start(ctx)
+	}
+
+	for {
+		at := next(time.Now())
+		if opts.Jitter > time.Duration(0) {
+			at = at.Add(rand.N(opts.Jitter))
+		}
+
+		timer := time.NewTimer(time.Until(at))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case err := <-runner.failed:
+			timer.Stop()
+			return err
+		case <-timer.C:
+			runner.start(ctx)
+		}
+	}
+}
+
+type taskRunner struct {
+	task Task
+	opts TaskOpts
+	log  log.Logger
+
+	wg     sync.WaitGroup
+	active atomic.Int64
+
+	failures uint64
+	failed   chan error
+	mu       sync.Mutex
+}
+
+func (r *taskRunner) start(ctx context.Context) {
+	if !r.opts.AllowOverlap && r.active.Load() > 0 {
+		r.log.Warn().Msg("Task is still running, skipping this run")
+		return
+	}
+
+	r.active.Add(1)
+	r.wg.Add(1)
+
+	go func() {
+		defer r.wg.Done()
+		defer r.active.Add(-1)
+
+		r.run(ctx)
+	}()
+}
+
+func (r *taskRunner) run(ctx context.Context) {
+	if r.opts.Timeout > time.Duration(0) {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, r.opts.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := r.task(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err == nil {
+		r.failures = 0
+		r.log.Debug().Dur("duration", time.Since(start)).Msg("Task finished")
+
+		return
+	}
+
+	r.failures++
+	r.log.Error().Err(err).Dur("duration", time.Since(start)).Uint64("failures", r.failures).Msg("Task failed")
+
+	if r.opts.FailureThreshold > 0 && r.failures >= r.opts.FailureThreshold {
+		select {
+		case r.failed <- errors.Wrapf(err, "task failed %d times in a row", r.failures):
+		default:
+		}
+	}
+}
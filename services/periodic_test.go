@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/homier/appetizer/log"
+)
+
+func TestPeriodic_Init(t *testing.T) {
+	logger := log.New(io.Discard, log.FormatJSON)
+
+	assert.Error(t, (&Periodic{Interval: time.Second}).Init(logger))
+	assert.Error(t, (&Periodic{Task: func(context.Context) error { return nil }}).Init(logger))
+	assert.NoError(t, (&Periodic{Task: func(context.Context) error { return nil }, Interval: time.Second}).Init(logger))
+}
+
+func TestPeriodic_Run(t *testing.T) {
+	t.Run("Runs every interval", func(t *testing.T) {
+		runs := atomic.Int64{}
+
+		p := &Periodic{
+			Task: func(context.Context) error {
+				runs.Add(1)
+				return nil
+			},
+			Interval: time.Millisecond * 10,
+			Opts:     TaskOpts{RunOnStart: true, Jitter: time.Millisecond},
+		}
+		require.NoError(t, p.Init(log.New(io.Discard, log.FormatJSON)))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*55)
+		defer cancel()
+
+		assert.NoError(t, p.Run(ctx))
+		assert.GreaterOrEqual(t, runs.Load(), int64(3))
+	})
+
+	t.Run("No overlap", func(t *testing.T) {
+		active, maxActive := atomic.Int64{}, atomic.Int64{}
+
+		p := &Periodic{
+			Task: func(ctx context.Context) error {
+				current := active.Add(1)
+				defer active.Add(-1)
+
+				if current > maxActive.Load() {
+					maxActive.Store(current)
+				}
+
+				<-ctx.Done()
+				return nil
+			},
+			Interval: time.Millisecond * 5,
+			Opts:     TaskOpts{RunOnStart: true},
+		}
+		require.NoError(t, p.Init(log.New(io.Discard, log.FormatJSON)))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+
+		assert.NoError(t, p.Run(ctx))
+		assert.Equal(t, int64(1), maxActive.Load())
+		assert.Equal(t, int64(0), active.Load())
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		errCh := make(chan error, 1)
+
+		p := &Periodic{
+			Task: func(ctx context.Context) error {
+				<-ctx.Done()
+				errCh <- ctx.Err()
+
+				return ctx.Err()
+			},
+			Interval: time.Hour,
+			Opts:     TaskOpts{RunOnStart: true, Timeout: time.Millisecond * 10},
+		}
+		require.NoError(t, p.Init(log.New(io.Discard, log.FormatJSON)))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go func() { _ = p.Run(ctx) }()
+		assert.ErrorIs(t, <-errCh, context.DeadlineExceeded)
+	})
+
+	t.Run("Failure threshold", func(t *testing.T) {
+		errTask := errors.New("task error")
+		runs := atomic.Int64{}
+
+		p := &Periodic{
+			Task: func(context.Context) error {
+				runs.Add(1)
+				return errTask
+			},
+			Interval: time.Millisecond,
+			Opts:     TaskOpts{FailureThreshold: 3},
+		}
+		require.NoError(t, p.Init(log.New(io.Discard, log.FormatJSON)))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		assert.ErrorIs(t, p.Run(ctx), errTask)
+		assert.GreaterOrEqual(t, runs.Load(), int64(3))
+		assert.NoError(t, ctx.Err())
+	})
+}
+
+func TestCron(t *testing.T) {
+	logger := log.New(io.Discard, log.FormatJSON)
+
+	assert.Error(t, (&Cron{Task: func(context.Context) error { return nil }, Schedule: "* *"}).Init(logger))
+	assert.Error(t, (&Cron{Task: func(context.Context) error { return nil }}).Run(context.Background()))
+
+	location, err := time.LoadLocation("UTC")
+	require.NoError(t, err)
+
+	ran := make(chan struct{})
+	c := &Cron{
+		Task: func(context.Context) error {
+			close(ran)
+			return nil
+		},
+		Schedule: "0 3 * * *",
+		Location: location,
+		Opts:     TaskOpts{RunOnStart: true},
+	}
+	require.NoError(t, c.Init(logger))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.Run(ctx) }()
+
+	<-ran
+	cancel()
+	assert.NoError(t, <-errCh)
+}
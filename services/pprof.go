@@ -3,18 +3,20 @@ package services
 import (
 	"net/http"
 	"net/http/pprof"
+	"strings"
 )
 
 var DefaultProfilerURIPrefix = "/debug/pprof/"
 
 // Returns a net/http.ServeMux with pprof handlers registered.
+// Named profiles, e.g. "heap" or "goroutine", are served under the prefix too.
 func PprofMuxer(prefix string) *http.ServeMux {
 	if prefix == "" {
 		prefix = DefaultProfilerURIPrefix
 	}
 
 	muxer := http.NewServeMux()
-	muxer.HandleFunc(prefix, pprof.Index)
+	muxer.HandleFunc(prefix, pprofIndex(prefix))
 	muxer.HandleFunc(prefix+"cmdline", pprof.Cmdline)
 	muxer.HandleFunc(prefix+"profile", pprof.Profile)
 	muxer.HandleFunc(prefix+"symbol", pprof.Symbol)
@@ -22,3 +24,17 @@ func PprofMuxer(prefix string) *http.ServeMux {
 
 	return muxer
 }
+
+// Serves named profiles under any prefix, since pprof.Index only
+// recognizes them under the "/debug/pprof/" path.
+func pprofIndex(prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, prefix)
+		if name == "" || name == r.URL.Path {
+			pprof.Index(w, r)
+			return
+		}
+
+		pprof.Handler(name).ServeHTTP(w, r)
+	}
+}
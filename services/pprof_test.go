@@ -1 +1,41 @@
 package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPprofMuxer(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		path   string
+		body   string
+	}{
+		{name: "default index", path: "/debug/pprof/", body: "goroutine"},
+		{name: "default profile", path: "/debug/pprof/goroutine?debug=1", body: "goroutine profile:"},
+		{name: "custom index", prefix: "/admin/pprof/", path: "/admin/pprof/", body: "allocs"},
+		{name: "custom profile", prefix: "/admin/pprof/", path: "/admin/pprof/goroutine?debug=1", body: "goroutine profile:"},
+		{name: "custom heap", prefix: "/admin/pprof/", path: "/admin/pprof/heap?debug=1", body: "heap profile:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			PprofMuxer(tt.prefix).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), tt.body)
+		})
+	}
+
+	t.Run("unknown profile", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		PprofMuxer("/admin/pprof/").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/pprof/unknown", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/homier/appetizer/log"
+)
+
+// Output lines longer than this are split.
+const maxProcessLineSize = 64 * 1024
+
+// A servicer that runs an external command, e.g. a sidecar binary.
+// The command stdout and stderr are logged line by line with the "stream" field.
+//
+// If the command exits by itself with a non-zero status, `Run` returns an error,
+// so the command is restarted according to the service restart policy.
+// If it exits with zero status, the service is considered stopped.
+type Process struct {
+	// Command name or path. A name without path separators is looked up in PATH.
+	Path string
+	Args []string
+
+	// Environment variables in the "KEY=value" form,
+	// added to the environment of the current process.
+	Env []string
+
+	// Working directory. If empty, the current one is used.
+	Dir string
+
+	// A signal to stop the command with on context cancellation.
+	// On unix, the command runs in its own process group, and the signal
+	// is sent to the whole group, so the command children are stopped too.
+	// If nil, SIGTERM is used.
+	StopSignal os.Signal
+
+	// How long to wait for the command to exit after the stop signal,
+	// before killing it with SIGKILL. The rest of its process group
+	// is killed once the command has exited.
+	// If zero, the `DefaultGracefulStopTimeout` will be used.
+	StopTimeout time.Duration
+
+	path string
+	log  log.Logger
+}
+
+// Initializes Process instance, looking up the command.
+func (p *Process) Init(log log.Logger) error {
+	p.log = log
+
+	if p.Path == "" {
+		return errors.New("process path is not defined")
+	}
+
+	path, err := exec.LookPath(p.Path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find process '%s'", p.Path)
+	}
+
+	p.path = path
+	return nil
+}
+
+// Starts the command and waits for its exit.
+// On context cancellation, the command is stopped with `StopSignal`,
+// and is killed if it hasn't exited within `StopTimeout`.
+// Once the command has exited, the rest of its process group is killed.
+func (p *Process) Run(ctx context.Context) error {
+	if p.path == "" {
+		return errors.New("process is not initialized")
+	}
+
+	stopSignal := p.StopSignal
+	if stopSignal == nil {
+		stopSignal = syscall.SIGTERM
+	}
+
+	stopTimeout := p.StopTimeout
+	if stopTimeout <= time.Duration(0) {
+		stopTimeout = DefaultGracefulStopTimeout
+	}
+
+	cmd := exec.CommandContext(ctx, p.path, p.Args...)
+	cmd.Env = append(os.Environ(), p.Env...)
+	cmd.Dir = p.Dir
+	cmd.WaitDelay = stopTimeout
+	cmd.Cancel = func() error {
+		p.log.Info().Msgf("Stopping process with %s", stopSignal)
+		return signalProcessGroup(cmd.Process, stopSignal)
+	}
+	setProcessGroup(cmd)
+
+	stdout, err := newOutputPipe(p.log, "stdout")
+	if err != nil {
+		return err
+	}
+
+	stderr, err := newOutputPipe(p.log, "stderr")
+	if err != nil {
+		stdout.close()
+		return err
+	}
+
+	cmd.Stdout = stdout.writer
+	cmd.Stderr = stderr.writer
+
+	err = cmd.Start()
+
+	// The command has its own copies of the write ends now.
+	stdout.writer.Close()
+	stderr.writer.Close()
+
+	if err != nil {
+		stdout.close()
+		stderr.close()
+
+		return errors.Wrapf(err, "failed to start process '%s'", p.Path)
+	}
+
+	p.log.Info().Int("pid", cmd.Process.Pid).Msgf("Started process '%s'", p.Path)
+
+	// The output is read by the pipes, so this returns as soon as
+	// the command exits, even if its children keep the output open.
+	err = cmd.Wait()
+
+	// Neither the stop signal nor the WaitDelay kill reaches the children
+	// left behind by the command, so they are killed here.
+	_ = signalProcessGroup(cmd.Process, os.Kill)
+
+	stdout.wait(stopTimeout)
+	stderr.wait(stopTimeout)
+
+	if ctx.Err() != nil {
+		p.log.Info().Msgf("Process stopped: %s", cmd.ProcessState)
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "process '%s' failed", p.Path)
+	}
+
+	p.log.Info().Msg("Process exited")
+	return nil
+}
+
+// A pipe the command output is written to, and logged line by line from.
+type outputPipe struct {
+	writer *os.File
+	reader *os.File
+	done   chan struct{}
+}
+
+func newOutputPipe(log log.Logger, stream string) (*outputPipe, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create process %s pipe", stream)
+	}
+
+	pipe := &outputPipe{writer: writer, reader: reader, done: make(chan struct{})}
+
+	go func() {
+		defer close(pipe.done)
+
+		output := &lineLogger{log: log, stream: stream}
+		_, _ = io.Copy(output, reader)
+		output.flush()
+	}()
+
+	return pipe, nil
+}
+
+// Waits until the output is read to its end, or the timeout is reached,
+// e.g. if the pipe is held open by a process outside of the command group.
+func (o *outputPipe) wait(timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-o.done:
+	case <-timer.C:
+	}
+
+	o.close()
+}
+
+func (o *outputPipe) close() {
+	o.writer.Close()
+	o.reader.Close()
+	<-o.done
+}
+
+// An io.Writer that logs every written line.
+type lineLogger struct {
+	log    log.Logger
+	stream string
+	buf    []byte
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+
+	start := 0
+	for {
+		index := bytes.IndexByte(l.buf[start:], '\n')
+		if index < 0 {
+			break
+		}
+
+		l.logLine(l.buf[start : start+index])
+		start += index + 1
+	}
+
+	// Moves an incomplete line to the beginning of the buffer.
+	l.buf = append(l.buf[:0], l.buf[start:]...)
+	if len(l.buf) >= maxProcessLineSize {
+		l.flush()
+	}
+
+	return len(p), nil
+}
+
+func (l *lineLogger) flush() {
+	if len(l.buf) > 0 {
+		l.logLine(l.buf)
+		l.buf = l.buf[:0]
+	}
+}
+
+func (l *lineLogger) logLine(line []byte) {
+	l.log.Info().Str("stream", l.stream).Msg(string(bytes.TrimSuffix(line, []byte{'\r'})))
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package services
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Process groups aren't supported on this platform, so only the process itself is signalled.
+func setProcessGroup(*exec.Cmd) {}
+
+func signalProcessGroup(process *os.Process, signal os.Signal) error {
+	return process.Signal(signal)
+}
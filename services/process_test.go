@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/homier/appetizer/log"
+)
+
+func TestProcess_Init(t *testing.T) {
+	logger := log.New(io.Discard, log.FormatJSON)
+
+	assert.Error(t, (&Process{}).Init(logger))
+	assert.Error(t, (&Process{Path: "appetizer-missing-binary"}).Init(logger))
+	assert.NoError(t, (&Process{Path: "sh"}).Init(logger))
+}
+
+func TestProcess_Run(t *testing.T) {
+	t.Run("Output is logged", func(t *testing.T) {
+		buf := &syncBuffer{}
+
+		p := &Process{
+			Path: "sh",
+			Args: []string{"-c", `echo "hello $NAME"; printf 'oops' >&2`},
+			Env:  []string{"NAME=world"},
+		}
+		require.NoError(t, p.Init(log.New(buf, log.FormatJSON)))
+
+		assert.NoError(t, p.Run(context.Background()))
+		assert.Contains(t, buf.String(), `"stream":"stdout","time"`)
+		assert.Contains(t, buf.String(), `"message":"hello world"`)
+		assert.Contains(t, buf.String(), `"stream":"stderr"`)
+		assert.Contains(t, buf.String(), `"message":"oops"`)
+	})
+
+	t.Run("Crash", func(t *testing.T) {
+		p := &Process{Path: "sh", Args: []string{"-c", "exit 3"}}
+		require.NoError(t, p.Init(log.New(io.Discard, log.FormatJSON)))
+
+		err := p.Run(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exit status 3")
+	})
+
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{name: "Stop", script: `trap "exit 0" TERM; echo started; while :; do sleep 0.01; done`},
+		{name: "Kill", script: `trap "" TERM; echo started; while :; do sleep 0.01; done`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+
+			p := &Process{Path: "sh", Args: []string{"-c", tt.script}, StopTimeout: time.Millisecond * 100}
+			require.NoError(t, p.Init(log.New(writerFunc(func(b []byte) (int, error) {
+				if bytes.Contains(b, []byte(`"message":"started"`)) {
+					close(started)
+				}
+
+				return len(b), nil
+			}), log.FormatJSON)))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			errCh := make(chan error, 1)
+			go func() { errCh <- p.Run(ctx) }()
+
+			<-started
+			cancel()
+
+			select {
+			case err := <-errCh:
+				assert.NoError(t, err)
+			case <-time.After(time.Second * 2):
+				t.Fatal("process hasn't been stopped")
+			}
+		})
+	}
+
+	t.Run("Children are stopped", func(t *testing.T) {
+		buf := &syncBuffer{}
+
+		// The child keeps the output open, so the process is waited for until it exits.
+		p := &Process{Path: "sh", Args: []string{"-c", "sleep 30 & echo started; wait"}, StopTimeout: time.Second}
+		require.NoError(t, p.Init(log.New(buf, log.FormatJSON)))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 1)
+		go func() { errCh <- p.Run(ctx) }()
+
+		require.Eventually(t, func() bool {
+			return strings.Contains(buf.String(), `"message":"started"`)
+		}, time.Second, time.Millisecond*10)
+		cancel()
+
+		select {
+		case err := <-errCh:
+			assert.NoError(t, err)
+		case <-time.After(time.Millisecond * 500):
+			t.Fatal("process children haven't been stopped")
+		}
+	})
+
+	t.Run("Children are killed on exit", func(t *testing.T) {
+		pidFile := filepath.Join(t.TempDir(), "pid")
+
+		// The child keeps the output open after the process exits.
+		p := &Process{Path: "sh", Args: []string{"-c", "sleep 30 & echo $! > " + pidFile}, StopTimeout: time.Second * 2}
+		require.NoError(t, p.Init(log.New(io.Discard, log.FormatJSON)))
+
+		errCh := make(chan error, 1)
+		go func() { errCh <- p.Run(context.Background()) }()
+
+		select {
+		case err := <-errCh:
+			assert.NoError(t, err)
+		case <-time.After(time.Millisecond * 500):
+			t.Fatal("process hasn't returned after its exit")
+		}
+
+		data, err := os.ReadFile(pidFile)
+		require.NoError(t, err)
+
+		// The killed child could remain a zombie until it's reaped by init.
+		stat := filepath.Join("/proc", strings.TrimSpace(string(data)), "stat")
+		assert.Eventually(t, func() bool {
+			data, err := os.ReadFile(stat)
+			return err != nil || strings.Contains(string(data), ") Z ")
+		}, time.Second, time.Millisecond*10)
+	})
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package services
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// Starts the command in its own process group, so its children are signalled along with it.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// Sends the signal to the process group of the process started with `setProcessGroup`.
+func signalProcessGroup(process *os.Process, signal os.Signal) error {
+	sig, ok := signal.(syscall.Signal)
+	if !ok {
+		return process.Signal(signal)
+	}
+
+	err := syscall.Kill(-process.Pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+
+	return err
+}
@@ -0,0 +1,345 @@
+package services
+
+import (
+	"context"
+	stdErrors "errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/metrics"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/homier/appetizer"
+	"github.com/homier/appetizer/log"
+)
+
+// A name of the CPU profile. Other profile names are the ones
+// of runtime/pprof.Lookup, e.g. "heap", "allocs" or "goroutine".
+const ProfileCPU = "cpu"
+
+var (
+	DefaultProfiles                 = []string{ProfileCPU, "heap", "goroutine"}
+	DefaultProfileCPUDuration       = time.Second * 10
+	DefaultProfileMaxFiles          = 10
+	DefaultProfileHeapCheckInterval = time.Second * 5
+)
+
+// Reasons of profile dumps, they're part of the file names.
+const (
+	ProfileReasonInterval  = "interval"
+	ProfileReasonSignal    = "signal"
+	ProfileReasonThreshold = "threshold"
+	ProfileReasonManual    = "manual"
+)
+
+// A servicer that writes profiles to a local directory, so they could be
+// inspected after an incident. Profiles are dumped every `Interval`,
+// on `Signals`, when the heap grows over `HeapThreshold`, or by `Dump`.
+//
+// Files are named "<profile>-<UTC time>-<reason>.pprof",
+// e.g. "heap-20240801T100000.000Z-signal.pprof", and are readable
+// with `go tool pprof`. Only a few latest files of every profile are kept,
+// see `MaxFiles` and `MaxAge`.
+type ProfileDumper struct {
+	// A directory to write profiles to. It's created if it doesn't exist.
+	Dir string
+
+	// Profiles to dump, see `ProfileCPU` and runtime/pprof.Lookup.
+	// If empty, the `DefaultProfiles` will be used.
+	Profiles []string
+
+	// How long to record the CPU profile for.
+	// If zero, the `DefaultProfileCPUDuration` will be used.
+	CPUDuration time.Duration
+
+	// If positive, profiles are dumped every interval.
+	Interval time.Duration
+
+	// Signals that trigger a dump, e.g. syscall.SIGUSR2.
+	Signals []os.Signal
+
+	// If positive, profiles are dumped once the heap objects size
+	// exceeds this many bytes. Another dump happens only after the heap
+	// shrinks below the threshold and exceeds it again.
+	HeapThreshold uint64
+
+	// How often to check the heap size, if `HeapThreshold` is set.
+	// If zero, the `DefaultProfileHeapCheckInterval` will be used.
+	HeapCheckInterval time.Duration
+
+	// How many latest files of every profile to keep.
+	// If zero, the `DefaultProfileMaxFiles` will be used.
+	MaxFiles int
+
+	// If positive, files older than this are removed.
+	MaxAge time.Duration
+
+	dumpMu   sync.Mutex
+	lastDump time.Time
+	ready    appetizer.Waiter
+	log      log.Logger
+}
+
+// Initializes ProfileDumper instance, validating its configuration.
+func (pd *ProfileDumper) Init(log log.Logger) error {
+	pd.log = log
+
+	if pd.Dir == "" {
+		return errors.New("profile directory is not defined")
+	}
+
+	for _, name := range pd.profiles() {
+		if name != ProfileCPU && pprof.Lookup(name) == nil {
+			return errors.Errorf("unknown profile '%s'", name)
+		}
+	}
+
+	return nil
+}
+
+// Blocks until the dumper is watching its triggers,
+// or the provided context is either cancelled or timed out.
+// If context is cancelled/timed out, a context error is returning,
+// otherwise no error is returning.
+func (pd *ProfileDumper) Wait(ctx context.Context) error {
+	return pd.ready.Wait(ctx)
+}
+
+// Returns a channel, that will be closed when the dumper is watching its triggers.
+func (pd *ProfileDumper) WaitCh() <-chan struct{} {
+	return pd.ready.WaitCh()
+}
+
+// Dumps profiles on the triggers until the context is done.
+// Dumps are sequential, so triggers fired during a dump are coalesced.
+func (pd *ProfileDumper) Run(ctx context.Context) error {
+	var interval <-chan time.Time
+	if pd.Interval > time.Duration(0) {
+		ticker := time.NewTicker(pd.Interval)
+		defer ticker.Stop()
+
+		interval = ticker.C
+	}
+
+	var signals chan os.Signal
+	if len(pd.Signals) > 0 {
+		signals = make(chan os.Signal, 1)
+
+		signal.Notify(signals, pd.Signals...)
+		defer signal.Stop(signals)
+	}
+
+	var heapCheck <-chan time.Time
+	if pd.HeapThreshold > 0 {
+		every := pd.HeapCheckInterval
+		if every <= time.Duration(0) {
+			every = DefaultProfileHeapCheckInterval
+		}
+
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+
+		heapCheck = ticker.C
+	}
+
+	pd.ready.Set(true)
+	defer pd.ready.Set(false)
+
+	exceeded := false
+
+	for {
+		var reason string
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-interval:
+			reason = ProfileReasonInterval
+		case <-signals:
+			reason = ProfileReasonSignal
+		case <-heapCheck:
+			heap := readMetricUint64(metricHeapObjects)
+			if heap <= pd.HeapThreshold {
+				exceeded = false
+				continue
+			}
+
+			if exceeded {
+				continue
+			}
+
+			exceeded = true
+			reason = ProfileReasonThreshold
+
+			pd.log.Warn().Uint64("heap_bytes", heap).Uint64("threshold", pd.HeapThreshold).
+				Msg("Heap size exceeded the threshold")
+		}
+
+		if _, err := pd.Dump(ctx, reason); err != nil {
+			pd.log.Error().Err(err).Str("reason", reason).Msg("Failed to dump profiles")
+		}
+	}
+}
+
+// Dumps the profiles right away, returning paths of the written files.
+// The `reason` becomes a part of the file names, see `ProfileReason*`.
+// It's safe to call concurrently with `Run`, dumps are sequential.
+func (pd *ProfileDumper) Dump(ctx context.Context, reason string) ([]string, error) {
+	pd.dumpMu.Lock()
+	defer pd.dumpMu.Unlock()
+
+	if reason == "" {
+		reason = ProfileReasonManual
+	}
+
+	reason = strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(reason)
+
+	if err := os.MkdirAll(pd.Dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "failed to create profile directory")
+	}
+
+	// Dumps within the same millisecond get the next one,
+	// so they don't overwrite each other and still sort by time.
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if !now.After(pd.lastDump) {
+		now = pd.lastDump.Add(time.Millisecond)
+	}
+	pd.lastDump = now
+
+	stamp := now.Format("20060102T150405.000Z")
+	paths := make([]string, 0, len(pd.profiles()))
+
+	var errs error
+	for _, name := range pd.profiles() {
+		path := filepath.Join(pd.Dir, name+"-"+stamp+"-"+reason+".pprof")
+		if err := pd.write(ctx, name, path); err != nil {
+			errs = stdErrors.Join(errs, errors.Wrapf(err, "failed to dump %s profile", name))
+			continue
+		}
+
+		paths = append(paths, path)
+	}
+
+	pd.cleanup()
+
+	if len(paths) > 0 {
+		pd.log.Info().Str("reason", reason).Strs("paths", paths).Msg("Profiles dumped")
+	}
+
+	return paths, errs
+}
+
+func (pd *ProfileDumper) profiles() []string {
+	if len(pd.Profiles) == 0 {
+		return DefaultProfiles
+	}
+
+	return pd.Profiles
+}
+
+// Writes the profile to a temporary file first, so incomplete files never appear.
+func (pd *ProfileDumper) write(ctx context.Context, name, path string) error {
+	f, err := os.CreateTemp(pd.Dir, "."+name+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if name == ProfileCPU {
+		err = pd.writeCPU(ctx, f)
+	} else {
+		err = pprof.Lookup(name).WriteTo(f, 0)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func (pd *ProfileDumper) writeCPU(ctx context.Context, f *os.File) error {
+	duration := pd.CPUDuration
+	if duration <= time.Duration(0) {
+		duration = DefaultProfileCPUDuration
+	}
+
+	// Fails if CPU profiling is already enabled, e.g. by the pprof handlers.
+	if err := pprof.StartCPUProfile(f); err != nil {
+		return err
+	}
+	defer pprof.StopCPUProfile()
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+
+	return nil
+}
+
+// Removes the files exceeding `MaxFiles` or `MaxAge` for every profile.
+func (pd *ProfileDumper) cleanup() {
+	maxFiles := pd.MaxFiles
+	if maxFiles <= 0 {
+		maxFiles = DefaultProfileMaxFiles
+	}
+
+	for _, name := range pd.profiles() {
+		paths, err := filepath.Glob(filepath.Join(pd.Dir, name+"-*.pprof"))
+		if err != nil {
+			continue
+		}
+
+		// Names of the same profile differ in time and reason, so they sort by time.
+		sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+
+		for i, path := range paths {
+			if i < maxFiles && !pd.expired(path) {
+				continue
+			}
+
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				pd.log.Warn().Err(err).Str("path", path).Msg("Failed to remove old profile")
+			}
+		}
+	}
+}
+
+func (pd *ProfileDumper) expired(path string) bool {
+	if pd.MaxAge <= time.Duration(0) {
+		return false
+	}
+
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) > pd.MaxAge
+}
+
+// A runtime/metrics name of the heap size occupied by objects.
+const metricHeapObjects = "/memory/classes/heap/objects:bytes"
+
+// Returns a value of the runtime/metrics metric, or zero if it isn't supported.
+func readMetricUint64(name string) uint64 {
+	sample := []metrics.Sample{{Name: name}}
+	metrics.Read(sample)
+
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+
+	return sample[0].Value.Uint64()
+}
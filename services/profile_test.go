@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/homier/appetizer/log"
+)
+
+func TestProfileDumper_Init(t *testing.T) {
+	pd := &ProfileDumper{}
+	assert.ErrorContains(t, pd.Init(log.New(io.Discard, log.FormatJSON)), "directory is not defined")
+
+	pd = &ProfileDumper{Dir: t.TempDir(), Profiles: []string{"heap", "unknown"}}
+	assert.ErrorContains(t, pd.Init(log.New(io.Discard, log.FormatJSON)), "unknown profile 'unknown'")
+}
+
+func TestProfileDumper_Dump(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles")
+
+	pd := &ProfileDumper{
+		Dir:         dir,
+		CPUDuration: time.Millisecond * 50,
+		MaxFiles:    3,
+	}
+	require.NoError(t, pd.Init(log.New(io.Discard, log.FormatJSON)))
+
+	paths, err := pd.Dump(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, paths, 3)
+
+	for i, name := range []string{"cpu", "heap", "goroutine"} {
+		assert.Regexp(t, `^`+name+`-\d{8}T\d{6}\.\d{3}Z-manual\.pprof$`, filepath.Base(paths[i]))
+
+		info, err := os.Stat(paths[i])
+		if assert.NoError(t, err) {
+			assert.Positive(t, info.Size())
+		}
+	}
+
+	pd.Profiles = []string{"heap"}
+	// Dumps within the same millisecond don't overwrite each other.
+	for _, reason := range []string{"first", "second", "second"} {
+		_, err := pd.Dump(context.Background(), reason)
+		require.NoError(t, err)
+	}
+
+	heaps, err := filepath.Glob(filepath.Join(dir, "heap-*.pprof"))
+	require.NoError(t, err)
+
+	if assert.Len(t, heaps, 3) {
+		assert.Contains(t, heaps[0], "-first.pprof")
+		assert.Contains(t, heaps[1], "-second.pprof")
+		assert.Contains(t, heaps[2], "-second.pprof")
+	}
+
+	temps, err := filepath.Glob(filepath.Join(dir, ".*.tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, temps)
+
+	t.Run("Max age", func(t *testing.T) {
+		old := time.Now().Add(-time.Hour)
+		require.NoError(t, os.Chtimes(heaps[0], old, old))
+
+		pd.MaxAge = time.Minute
+		_, err := pd.Dump(context.Background(), "third")
+		require.NoError(t, err)
+
+		_, err = os.Stat(heaps[0])
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		_, err = os.Stat(heaps[1])
+		assert.NoError(t, err)
+	})
+}
+
+func TestProfileDumper_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		dumper  *ProfileDumper
+		trigger func(t *testing.T)
+		reason  string
+	}{
+		{
+			name:   "interval",
+			dumper: &ProfileDumper{Interval: time.Millisecond * 10},
+			reason: ProfileReasonInterval,
+		},
+		{
+			name:   "heap threshold",
+			dumper: &ProfileDumper{HeapThreshold: 1, HeapCheckInterval: time.Millisecond * 10},
+			reason: ProfileReasonThreshold,
+		},
+		{
+			name:   "signal",
+			dumper: &ProfileDumper{Signals: []os.Signal{os.Interrupt}},
+			trigger: func(t *testing.T) {
+				process, err := os.FindProcess(os.Getpid())
+				require.NoError(t, err)
+				require.NoError(t, process.Signal(os.Interrupt))
+			},
+			reason: ProfileReasonSignal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pd := tt.dumper
+			pd.Dir = t.TempDir()
+			pd.Profiles = []string{"goroutine"}
+			require.NoError(t, pd.Init(log.New(io.Discard, log.FormatJSON)))
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+			defer cancel()
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- pd.Run(ctx) }()
+
+			require.NoError(t, pd.Wait(ctx))
+			if tt.trigger != nil {
+				tt.trigger(t)
+			}
+
+			pattern := filepath.Join(pd.Dir, "goroutine-*-"+tt.reason+".pprof")
+			assert.Eventually(t, func() bool {
+				paths, _ := filepath.Glob(pattern)
+				return len(paths) > 0
+			}, time.Second, time.Millisecond*10)
+
+			cancel()
+			assert.NoError(t, <-errCh)
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package services
+
+import (
+	"context"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/homier/appetizer"
+	"github.com/homier/appetizer/log"
+)
+
+var (
+	DefaultTCPAddress = "127.0.0.1:9100"
+
+	// The longest delay between retries of temporary accept errors,
+	// e.g. when the process runs out of file descriptors.
+	MaxAcceptRetryDelay = time.Second
+)
+
+// Handles a TCP connection, which is closed once the handler returns.
+// The connection logger is available within `ctx`, see `log.FromContext`.
+// The context is cancelled when the connection is closed on stop: right away,
+// or once the graceful stop times out, so the connections are drained in peace.
+// Long-lived handlers should watch `StoppingFromContext` to finish early
+// once the server starts stopping, e.g. after the current request.
+type ConnHandler func(ctx context.Context, conn net.Conn) error
+
+type stoppingKey struct{}
+
+// Returns a channel that is closed once the server handling the connection
+// or the packet starts stopping, see `ConnHandler` and `PacketHandler`.
+// Returns nil, which blocks forever, if `ctx` isn't a handler one.
+func StoppingFromContext(ctx context.Context) <-chan struct{} {
+	stopping, _ := ctx.Value(stoppingKey{}).(<-chan struct{})
+	return stopping
+}
+
+// Returns a context for the handlers, that isn't cancelled along with `ctx`,
+// but tells when it's done through `StoppingFromContext`.
+func handlerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithCancel(context.WithValue(context.WithoutCancel(ctx), stoppingKey{}, ctx.Done()))
+}
+
+// High level TCP server configuration.
+type TCPServerConfig struct {
+	// A network to listen on: "tcp", "tcp4", "tcp6" or "unix".
+	// For "unix", `Address` is a socket file path.
+	Network string `json:"network" default:"tcp"`
+
+	// An address to listen on. Use port 0 to listen on an ephemeral port,
+	// the actual address is available through `TCPServer.Addr`.
+	Address string `json:"address" default:"127.0.0.1:9100"`
+
+	// Maximum number of connections handled at once. Once it's reached,
+	// new connections wait in the listen backlog. If zero, there is no limit.
+	MaxConnections int `json:"max_connections" default:"0"`
+
+	// If positive, every read or write fails if it doesn't complete in time.
+	ReadTimeout  time.Duration `json:"read_timeout" default:"0s"`
+	WriteTimeout time.Duration `json:"write_timeout" default:"0s"`
+}
+
+// A TCP server that implements appetizer.Servicer interface.
+// Every connection is handled by `Handler` in its own goroutine.
+type TCPServer struct {
+	// Server configuration
+	Config TCPServerConfig
+
+	Handler ConnHandler
+
+	// If set, the server accepts connections on this listener,
+	// and `Config.Network` and `Config.Address` are ignored.
+	// A TCP or Unix listener is duplicated, so it could be served again
+	// on restart, see `HTTPServer.Listener`.
+	Listener net.Listener
+
+	// Whether to stop server gracefully or not.
+	// If enabled, the server stops accepting connections and waits for
+	// the active ones to be handled. Otherwise, they're closed immediately.
+	GracefulStopEnabled bool
+
+	// If graceful stop is enabled, this timeout will be used
+	// to wait until its stop. If timeout has reached,
+	// remaining connections are closed.
+	// If zero, the `DefaultGracefulStopTimeout` will be used.
+	GracefulStopTimeout time.Duration
+
+	addr  net.Addr
+	ready appetizer.Waiter
+
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+
+	log log.Logger
+	mu  sync.Mutex
+}
+
+// Initializes TCPServer instance, validating its configuration.
+func (ts *TCPServer) Init(log log.Logger) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.log = log
+
+	if ts.Handler == nil {
+		return errors.New("TCP connection handler is not defined")
+	}
+
+	if ts.Config.Address == "" {
+		ts.Config.Address = DefaultTCPAddress
+	}
+
+	return nil
+}
+
+// Returns the address the server is listening on,
+// or nil if it hasn't started listening yet. See `WaitCh`.
+func (ts *TCPServer) Addr() net.Addr {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	return ts.addr
+}
+
+// Blocks until the server is listening,
+// or the provided context is either cancelled or timed out.
+// If context is cancelled/timed out, a context error is returning,
+// otherwise no error is returning.
+func (ts *TCPServer) Wait(ctx context.Context) error {
+	return ts.ready.Wait(ctx)
+}
+
+// Returns a channel, that will be closed when the server is listening.
+func (ts *TCPServer) WaitCh() <-chan struct{} {
+	return ts.ready.WaitCh()
+}
+
+// Accepts connections until the context is done or accepting fails.
+// On context cancellation, the active connections are drained
+// if graceful stop is enabled, or closed otherwise.
+func (ts *TCPServer) Run(ctx context.Context) error {
+	if ts.Handler == nil {
+		return errors.New("TCP server is not initialized")
+	}
+
+	listener, err := listen(ts.Listener, ts.Config.Network, ts.Config.Address)
+	if err != nil {
+		return err
+	}
+
+	ts.mu.Lock()
+	ts.addr = listener.Addr()
+	ts.mu.Unlock()
+
+	ts.log.Info().Msgf("Listening on %s://%s", listener.Addr().Network(), listener.Addr())
+	ts.ready.Set(true)
+	defer ts.ready.Set(false)
+
+	// Connections are handled with their own context,
+	// so they aren't interrupted right on graceful stop.
+	handleCtx, cancelHandle := handlerContext(ctx)
+	defer cancelHandle()
+
+	acceptCh := make(chan error, 1)
+	go func() { acceptCh <- ts.accept(ctx, handleCtx, listener) }()
+
+	select {
+	case err = <-acceptCh:
+		cancelHandle()
+		ts.closeConns()
+	case <-ctx.Done():
+		_ = listener.Close()
+		<-acceptCh
+
+		ts.stop(cancelHandle)
+	}
+
+	ts.wg.Wait()
+	return err
+}
+
+func (ts *TCPServer) accept(ctx, handleCtx context.Context, listener net.Listener) error {
+	var slots chan struct{}
+	if ts.Config.MaxConnections > 0 {
+		slots = make(chan struct{}, ts.Config.MaxConnections)
+	}
+
+	var retryDelay time.Duration
+
+	for {
+		if slots != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case slots <- struct{}{}:
+			}
+		}
+
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			if slots != nil {
+				<-slots
+			}
+
+			if !isTemporaryAcceptError(err) {
+				_ = listener.Close()
+				return errors.Wrap(err, "failed to accept TCP connection")
+			}
+
+			// Retries with a growing delay, as net/http.Server does.
+			retryDelay = min(max(retryDelay*2, time.Millisecond*5), MaxAcceptRetryDelay)
+			ts.log.Warn().Err(err).Msgf("Failed to accept TCP connection, retrying in %s", retryDelay)
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(retryDelay):
+			}
+
+			continue
+		}
+
+		retryDelay = 0
+
+		ts.track(conn, true)
+		ts.wg.Add(1)
+
+		go func() {
+			defer ts.wg.Done()
+			defer func() {
+				if slots != nil {
+					<-slots
+				}
+			}()
+
+			ts.handle(handleCtx, conn)
+		}()
+	}
+}
+
+func (ts *TCPServer) handle(ctx context.Context, conn net.Conn) {
+	defer ts.track(conn, false)
+	defer conn.Close()
+
+	connLog := ts.log.With().
+		Str("conn_id", newRequestID()).
+		Str("remote_addr", conn.RemoteAddr().String()).
+		Logger()
+
+	start := time.Now()
+	connLog.Debug().Msg("Connection accepted")
+
+	if ts.Config.ReadTimeout > time.Duration(0) || ts.Config.WriteTimeout > time.Duration(0) {
+		conn = &deadlineConn{Conn: conn, readTimeout: ts.Config.ReadTimeout, writeTimeout: ts.Config.WriteTimeout}
+	}
+
+	if err := ts.Handler(log.WithContext(ctx, connLog), conn); err != nil {
+		connLog.Error().Err(err).Dur("duration", time.Since(start)).Msg("Connection handler failed")
+		return
+	}
+
+	connLog.Debug().Dur("duration", time.Since(start)).Msg("Connection closed")
+}
+
+// Waits for the active connections if graceful stop is enabled,
+// closing the remaining ones once the timeout is reached.
+// The handlers context is cancelled with `cancel` along with closing.
+func (ts *TCPServer) stop(cancel context.CancelFunc) {
+	if !ts.GracefulStopEnabled {
+		cancel()
+		ts.closeConns()
+		return
+	}
+
+	timeout := ts.GracefulStopTimeout
+	if timeout <= time.Duration(0) {
+		timeout = DefaultGracefulStopTimeout
+	}
+
+	ts.log.Info().Msgf("Draining %d active connections", ts.activeConns())
+	if !waitTimeout(&ts.wg, timeout) {
+		ts.log.Warn().Msgf("Graceful stop timed out after %s, closing %d active connections", timeout, ts.activeConns())
+		cancel()
+		ts.closeConns()
+	}
+}
+
+func (ts *TCPServer) track(conn net.Conn, active bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if !active {
+		delete(ts.conns, conn)
+		return
+	}
+
+	if ts.conns == nil {
+		ts.conns = make(map[net.Conn]struct{})
+	}
+
+	ts.conns[conn] = struct{}{}
+}
+
+func (ts *TCPServer) activeConns() int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	return len(ts.conns)
+}
+
+func (ts *TCPServer) closeConns() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	for conn := range ts.conns {
+		_ = conn.Close()
+	}
+}
+
+// Reports whether the accept error is likely to go away by itself,
+// e.g. running out of file descriptors or an aborted connection.
+func isTemporaryAcceptError(err error) bool {
+	for _, errno := range []error{
+		syscall.EMFILE, syscall.ENFILE, syscall.ENOBUFS, syscall.ENOMEM,
+		syscall.ECONNABORTED, syscall.ECONNRESET, syscall.EINTR, syscall.EAGAIN,
+	} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// A connection that sets a deadline before every read and write.
+type deadlineConn struct {
+	net.Conn
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	if c.readTimeout > time.Duration(0) {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return 0, err
+		}
+	}
+
+	return c.Conn.Read(p)
+}
+
+func (c *deadlineConn) Write(p []byte) (int, error) {
+	if c.writeTimeout > time.Duration(0) {
+		if err := c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+			return 0, err
+		}
+	}
+
+	return c.Conn.Write(p)
+}
+
+// Waits for `wg` within the timeout, returning false if it's reached.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		wg.Wait()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
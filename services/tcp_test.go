@@ -0,0 +1,313 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/homier/appetizer/log"
+)
+
+func runTCPServer(t *testing.T, ts *TCPServer) (context.CancelFunc, <-chan error) {
+	t.Helper()
+
+	ts.Config.Address = "127.0.0.1:0"
+	require.NoError(t, ts.Init(log.New(io.Discard, log.FormatJSON)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	t.Cleanup(cancel)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- ts.Run(ctx) }()
+
+	require.NoError(t, ts.Wait(ctx))
+	return cancel, errCh
+}
+
+// Echoes lines back until the client closes the connection.
+func echoLines(_ context.Context, conn net.Conn) error {
+	reader := bufio.NewReader(conn)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(conn, line); err != nil {
+			return err
+		}
+	}
+}
+
+func assertEcho(t *testing.T, conn net.Conn, line string) {
+	t.Helper()
+
+	_, err := io.WriteString(conn, line+"\n")
+	require.NoError(t, err)
+
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, line+"\n", reply)
+}
+
+func TestTCPServer(t *testing.T) {
+	assert.Error(t, (&TCPServer{}).Init(log.New(io.Discard, log.FormatJSON)))
+
+	ts := &TCPServer{Handler: echoLines}
+	cancel, errCh := runTCPServer(t, ts)
+
+	conn, err := net.Dial("tcp", ts.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assertEcho(t, conn, "hello")
+	assertEcho(t, conn, "world")
+
+	cancel()
+	assert.NoError(t, <-errCh)
+
+	// The connection is closed without graceful stop.
+	_, err = bufio.NewReader(conn).ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestTCPServer_MaxConnections(t *testing.T) {
+	ts := &TCPServer{Config: TCPServerConfig{MaxConnections: 1}, Handler: echoLines}
+	cancel, errCh := runTCPServer(t, ts)
+
+	first, err := net.Dial("tcp", ts.Addr().String())
+	require.NoError(t, err)
+
+	assertEcho(t, first, "first")
+
+	// Connected through the backlog, but not accepted until the first one is closed.
+	second, err := net.Dial("tcp", ts.Addr().String())
+	require.NoError(t, err)
+	defer second.Close()
+
+	_, err = io.WriteString(second, "second\n")
+	require.NoError(t, err)
+
+	require.NoError(t, second.SetReadDeadline(time.Now().Add(time.Millisecond*50)))
+	_, err = bufio.NewReader(second).ReadString('\n')
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	require.NoError(t, first.Close())
+	require.NoError(t, second.SetReadDeadline(time.Time{}))
+
+	reply, err := bufio.NewReader(second).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", reply)
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+func TestTCPServer_ReadTimeout(t *testing.T) {
+	handlerErr := make(chan error, 1)
+
+	ts := &TCPServer{
+		Config: TCPServerConfig{ReadTimeout: time.Millisecond * 20},
+		Handler: func(ctx context.Context, conn net.Conn) error {
+			err := echoLines(ctx, conn)
+			handlerErr <- err
+
+			return err
+		},
+	}
+	cancel, errCh := runTCPServer(t, ts)
+
+	conn, err := net.Dial("tcp", ts.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assertEcho(t, conn, "hello")
+	assert.ErrorIs(t, <-handlerErr, os.ErrDeadlineExceeded)
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+func TestTCPServer_Stopping(t *testing.T) {
+	accepted := make(chan struct{})
+
+	ts := &TCPServer{
+		// A long-lived connection, that is finished once the server is stopping.
+		Handler: func(ctx context.Context, conn net.Conn) error {
+			close(accepted)
+
+			select {
+			case <-StoppingFromContext(ctx):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			_, err := io.WriteString(conn, "bye\n")
+			return err
+		},
+		GracefulStopEnabled: true,
+		GracefulStopTimeout: time.Second * 2,
+	}
+	cancel, errCh := runTCPServer(t, ts)
+
+	conn, err := net.Dial("tcp", ts.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	<-accepted
+	cancel()
+
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "bye\n", reply)
+
+	select {
+	case err := <-errCh:
+		assert.NoError(t, err)
+	case <-time.After(time.Millisecond * 500):
+		t.Fatal("server hasn't been stopped before the graceful stop timeout")
+	}
+
+	assert.Nil(t, StoppingFromContext(context.Background()))
+}
+
+// A listener that fails to accept with `errs` first.
+type failingListener struct {
+	net.Listener
+
+	errs []error
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	if len(l.errs) > 0 {
+		err := l.errs[0]
+		l.errs = l.errs[1:]
+
+		return nil, err
+	}
+
+	return l.Listener.Accept()
+}
+
+func TestTCPServer_AcceptErrors(t *testing.T) {
+	acceptErr := func(err error) error {
+		return &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept", err)}
+	}
+
+	t.Run("Temporary errors are retried", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		ts := &TCPServer{
+			Handler: echoLines,
+			Listener: &failingListener{
+				Listener: listener,
+				errs:     []error{acceptErr(syscall.EMFILE), acceptErr(syscall.ECONNABORTED)},
+			},
+			Config: TCPServerConfig{MaxConnections: 1},
+		}
+		cancel, errCh := runTCPServer(t, ts)
+
+		conn, err := net.Dial("tcp", ts.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		assertEcho(t, conn, "hello")
+
+		cancel()
+		assert.NoError(t, <-errCh)
+	})
+
+	t.Run("Permanent errors fail the server", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		ts := &TCPServer{
+			Handler:  echoLines,
+			Listener: &failingListener{Listener: listener, errs: []error{acceptErr(syscall.EINVAL)}},
+		}
+		require.NoError(t, ts.Init(log.New(io.Discard, log.FormatJSON)))
+
+		assert.ErrorIs(t, ts.Run(context.Background()), syscall.EINVAL)
+	})
+}
+
+func TestTCPServer_GracefulStop(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		release bool
+	}{
+		{name: "Active connections are drained", timeout: time.Second, release: true},
+		{name: "Active connections are closed on timeout", timeout: time.Millisecond * 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			accepted := make(chan struct{})
+
+			ts := &TCPServer{
+				Handler: func(ctx context.Context, conn net.Conn) error {
+					close(accepted)
+
+					readErr := make(chan error, 1)
+					go func() {
+						_, err := conn.Read(make([]byte, 1))
+						readErr <- err
+					}()
+
+					// The context is cancelled only once the drain times out.
+					select {
+					case <-release:
+						_, err := io.WriteString(conn, "bye\n")
+						return err
+					case err := <-readErr:
+						return err
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				},
+				GracefulStopEnabled: true,
+				GracefulStopTimeout: tt.timeout,
+			}
+			cancel, errCh := runTCPServer(t, ts)
+
+			conn, err := net.Dial("tcp", ts.Addr().String())
+			require.NoError(t, err)
+			defer conn.Close()
+
+			<-accepted
+			cancel()
+
+			if tt.release {
+				close(release)
+
+				reply, err := bufio.NewReader(conn).ReadString('\n')
+				require.NoError(t, err)
+				assert.Equal(t, "bye\n", reply)
+			}
+
+			select {
+			case err := <-errCh:
+				assert.NoError(t, err)
+			case <-time.After(time.Second):
+				t.Fatal("server hasn't been stopped")
+			}
+		})
+	}
+}
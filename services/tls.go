@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/homier/appetizer/log"
+)
+
+var DefaultTLSReloadInterval = time.Second * 10
+
+// Keeps a TLS certificate, its key and an optional client CA bundle loaded
+// from files, reloading them when the files change.
+// Use `Apply` to plug it into a *crypto/tls.Config.
+type TLSReloader struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+
+	cert      atomic.Pointer[tls.Certificate]
+	clientCAs atomic.Pointer[x509.CertPool]
+
+	mu       sync.Mutex
+	versions map[string]fileVersion
+}
+
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// Returns a reloader with the files loaded.
+// If `clientCAFile` is empty, client certificates are not verified.
+func NewTLSReloader(certFile, keyFile, clientCAFile string) (*TLSReloader, error) {
+	r := &TLSReloader{CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCAFile}
+	if _, err := r.Reload(true); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Configures `config` to use the reloaded certificate, and, if the client CA
+// file is defined, to require and verify client certificates against it.
+func (r *TLSReloader) Apply(config *tls.Config) {
+	config.GetCertificate = r.GetCertificate
+
+	if r.ClientCAFile == "" {
+		return
+	}
+
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		clientConfig := config.Clone()
+		clientConfig.GetConfigForClient = nil
+		clientConfig.ClientCAs = r.clientCAs.Load()
+
+		return clientConfig, nil
+	}
+}
+
+// Returns the current certificate. Could be used as tls.Config.GetCertificate.
+func (r *TLSReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := r.cert.Load()
+	if cert == nil {
+		return nil, errors.New("TLS certificate is not loaded")
+	}
+
+	return cert, nil
+}
+
+// Reloads the files if any of them has changed since the last reload,
+// or unconditionally if `force` is true.
+// Returns true if the files were reloaded. On error, the previously
+// loaded certificate is kept.
+func (r *TLSReloader) Reload(force bool) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	versions, err := r.fileVersions()
+	if err != nil {
+		return false, err
+	}
+
+	if !force && r.unchanged(versions) {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to load TLS certificate")
+	}
+
+	if r.ClientCAFile != "" {
+		pem, err := os.ReadFile(filepath.Clean(r.ClientCAFile))
+		if err != nil {
+			return false, errors.Wrap(err, "failed to read TLS client CA file")
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return false, errors.New("TLS client CA file has no valid certificates")
+		}
+
+		r.clientCAs.Store(pool)
+	}
+
+	r.cert.Store(&cert)
+	r.versions = versions
+
+	return true, nil
+}
+
+// Reloads the files when they change, blocking until the context is done.
+// Reload errors are logged. The files are watched with a `FileWatcher`,
+// and `interval` is used if they have to be polled.
+// If `interval` is not positive, the `DefaultTLSReloadInterval` will be used.
+func (r *TLSReloader) Watch(ctx context.Context, interval time.Duration, log log.Logger) {
+	if interval <= time.Duration(0) {
+		interval = DefaultTLSReloadInterval
+	}
+
+	paths := []string{r.CertFile, r.KeyFile}
+	if r.ClientCAFile != "" {
+		paths = append(paths, r.ClientCAFile)
+	}
+
+	watcher := &FileWatcher{
+		Paths:        paths,
+		PollInterval: interval,
+		OnChange: func(context.Context, []string) error {
+			reloaded, err := r.Reload(false)
+			if err != nil {
+				return errors.Wrap(err, "failed to reload TLS certificates")
+			}
+
+			if reloaded {
+				log.Info().Msg("TLS certificates reloaded")
+			}
+
+			return nil
+		},
+	}
+
+	if err := watcher.Init(log); err != nil {
+		log.Error().Err(err).Msg("Failed to watch TLS certificates")
+		return
+	}
+
+	_ = watcher.Run(ctx)
+}
+
+func (r *TLSReloader) fileVersions() (map[string]fileVersion, error) {
+	versions := make(map[string]fileVersion, 3)
+
+	for _, path := range []string{r.CertFile, r.KeyFile, r.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to stat TLS file '%s'", path)
+		}
+
+		versions[path] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	return versions, nil
+}
+
+func (r *TLSReloader) unchanged(versions map[string]fileVersion) bool {
+	if len(versions) != len(r.versions) {
+		return false
+	}
+
+	for path, version := range versions {
+		if previous, ok := r.versions[path]; !ok || !previous.modTime.Equal(version.modTime) ||
+			previous.size != version.size {
+			return false
+		}
+	}
+
+	return true
+}
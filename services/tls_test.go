@@ -0,0 +1,181 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/homier/appetizer/log"
+)
+
+// Writes a self-signed certificate for 127.0.0.1 and its key to `dir`,
+// returning their paths and the parsed certificate.
+func writeTestCert(t *testing.T, dir string, serial int64) (string, string, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "appetizer"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+
+	return certFile, keyFile, cert
+}
+
+func TestTLSReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeTestCert(t, dir, 1)
+
+	reloader, err := NewTLSReloader(certFile, keyFile, "")
+	require.NoError(t, err)
+
+	reloaded, err := reloader.Reload(false)
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	// Make sure the modification time differs on filesystems with a coarse resolution.
+	time.Sleep(time.Millisecond * 10)
+	_, _, cert := writeTestCert(t, dir, 2)
+
+	reloaded, err = reloader.Reload(false)
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+
+	current, err := reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, cert.Raw, current.Certificate[0])
+
+	_, err = NewTLSReloader(filepath.Join(dir, "missing.pem"), keyFile, "")
+	assert.Error(t, err)
+}
+
+func TestHTTPServer_TLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, cert := writeTestCert(t, dir, 1)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	hs := &HTTPServer{
+		Config: HTTPServerConfig{
+			Address:         "127.0.0.1:0",
+			TLSEnabled:      true,
+			TLSCertFile:     certFile,
+			TLSKeyFile:      keyFile,
+			TLSClientCAFile: certFile,
+		},
+		Handlers: []Handler{{
+			Path: "/hello",
+			Handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = io.WriteString(w, "world")
+			},
+		}},
+	}
+	require.NoError(t, hs.Init(log.New(io.Discard, log.FormatJSON)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- hs.Run(ctx) }()
+
+	require.NoError(t, hs.Wait(ctx))
+	address := hs.Addr().String()
+
+	clientCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{
+			RootCAs:      pool,
+			Certificates: []tls.Certificate{clientCert},
+			MinVersion:   tls.VersionTLS12,
+		},
+		ForceAttemptHTTP2: true,
+	}}
+
+	resp, err := client.Get("https://" + address + "/hello")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// HTTP/2 is negotiated with the per-client config too.
+	assert.Equal(t, "h2", resp.TLS.NegotiatedProtocol)
+	assert.Equal(t, 2, resp.ProtoMajor)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(body))
+
+	noCertClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}}}
+	_, err = noCertClient.Get("https://" + address + "/hello")
+	assert.Error(t, err)
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+func TestTLSReloader_Watch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeTestCert(t, dir, 1)
+
+	reloader, err := NewTLSReloader(certFile, keyFile, "")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go reloader.Watch(ctx, time.Millisecond*10, log.New(io.Discard, log.FormatJSON))
+
+	// Make sure the modification time differs on filesystems with a coarse resolution.
+	time.Sleep(time.Millisecond * 50)
+	_, _, cert := writeTestCert(t, dir, 2)
+
+	assert.Eventually(t, func() bool {
+		current, err := reloader.GetCertificate(nil)
+		return err == nil && bytes.Equal(cert.Raw, current.Certificate[0])
+	}, time.Second*2, time.Millisecond*10)
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/homier/appetizer"
+	"github.com/homier/appetizer/log"
+)
+
+var (
+	DefaultUDPAddress       = "127.0.0.1:9100"
+	DefaultUDPMaxPacketSize = 65535
+)
+
+// Handles a UDP packet received from `addr`. Use `conn` to reply.
+// The packet logger is available within `ctx`, see `log.FromContext`.
+// The context is cancelled when the server stops: right away,
+// or once the graceful stop times out, so the packets are drained in peace.
+// See `StoppingFromContext` to find out when the server starts stopping.
+type PacketHandler func(ctx context.Context, conn net.PacketConn, addr net.Addr, packet []byte) error
+
+// High level UDP server configuration.
+type UDPServerConfig struct {
+	// A network to listen on: "udp", "udp4", "udp6" or "unixgram".
+	// For "unixgram", `Address` is a socket file path.
+	Network string `json:"network" default:"udp"`
+
+	// An address to listen on. Use port 0 to listen on an ephemeral port,
+	// the actual address is available through `UDPServer.Addr`.
+	Address string `json:"address" default:"127.0.0.1:9100"`
+
+	// Maximum number of packets handled at once. Once it's reached,
+	// new packets wait in the socket buffer. If zero, there is no limit.
+	MaxConcurrency int `json:"max_concurrency" default:"0"`
+
+	// Maximum size of a packet in bytes, the rest is discarded.
+	// If zero, the `DefaultUDPMaxPacketSize` will be used.
+	MaxPacketSize int `json:"max_packet_size" default:"65535"`
+
+	// If positive, every reply fails if it isn't written in time.
+	WriteTimeout time.Duration `json:"write_timeout" default:"0s"`
+}
+
+// A UDP server that implements appetizer.Servicer interface.
+// Every packet is handled by `Handler` in its own goroutine.
+type UDPServer struct {
+	// Server configuration
+	Config UDPServerConfig
+
+	Handler PacketHandler
+
+	// If set, the server reads packets from this connection,
+	// and `Config.Network` and `Config.Address` are ignored.
+	// A UDP or Unix connection is duplicated and the duplicate is closed
+	// on stop, so the connection could be served again on restart.
+	// Other connections are closed on stop, and can't be served again.
+	Conn net.PacketConn
+
+	// Whether to stop server gracefully or not.
+	// If enabled, the server stops reading packets and waits for the received
+	// ones to be handled, so replies could still be sent.
+	// Otherwise, the connection is closed immediately.
+	GracefulStopEnabled bool
+
+	// If graceful stop is enabled, this timeout will be used
+	// to wait until its stop. If timeout has reached,
+	// the connection is closed.
+	// If zero, the `DefaultGracefulStopTimeout` will be used.
+	GracefulStopTimeout time.Duration
+
+	addr  net.Addr
+	ready appetizer.Waiter
+	wg    sync.WaitGroup
+
+	log log.Logger
+	mu  sync.Mutex
+}
+
+// Initializes UDPServer instance, validating its configuration.
+func (us *UDPServer) Init(log log.Logger) error {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	us.log = log
+
+	if us.Handler == nil {
+		return errors.New("UDP packet handler is not defined")
+	}
+
+	if us.Config.Address == "" {
+		us.Config.Address = DefaultUDPAddress
+	}
+
+	return nil
+}
+
+// Returns the address the server is listening on,
+// or nil if it hasn't started listening yet. See `WaitCh`.
+func (us *UDPServer) Addr() net.Addr {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	return us.addr
+}
+
+// Blocks until the server is listening,
+// or the provided context is either cancelled or timed out.
+// If context is cancelled/timed out, a context error is returning,
+// otherwise no error is returning.
+func (us *UDPServer) Wait(ctx context.Context) error {
+	return us.ready.Wait(ctx)
+}
+
+// Returns a channel, that will be closed when the server is listening.
+func (us *UDPServer) WaitCh() <-chan struct{} {
+	return us.ready.WaitCh()
+}
+
+// Reads packets until the context is done or reading fails.
+// On context cancellation, the received packets are drained
+// if graceful stop is enabled.
+func (us *UDPServer) Run(ctx context.Context) error {
+	if us.Handler == nil {
+		return errors.New("UDP server is not initialized")
+	}
+
+	conn, err := us.listen()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	us.mu.Lock()
+	us.addr = conn.LocalAddr()
+	us.mu.Unlock()
+
+	us.log.Info().Msgf("Listening on %s://%s", conn.LocalAddr().Network(), conn.LocalAddr())
+	us.ready.Set(true)
+	defer us.ready.Set(false)
+
+	// Packets are handled with their own context,
+	// so they aren't interrupted right on graceful stop.
+	handleCtx, cancelHandle := handlerContext(ctx)
+	defer cancelHandle()
+
+	readCh := make(chan error, 1)
+	go func() { readCh <- us.read(ctx, handleCtx, conn) }()
+
+	select {
+	case err = <-readCh:
+	case <-ctx.Done():
+		// Interrupts the pending read, keeping the connection open for replies.
+		_ = conn.SetReadDeadline(time.Now())
+		<-readCh
+
+		us.stop()
+	}
+
+	cancelHandle()
+	_ = conn.Close()
+	us.wg.Wait()
+
+	return err
+}
+
+func (us *UDPServer) listen() (net.PacketConn, error) {
+	if us.Conn != nil {
+		return duplicatePacketConn(us.Conn), nil
+	}
+
+	network := us.Config.Network
+	if network == "" {
+		network = "udp"
+	}
+
+	if network == "unixgram" {
+		removeStaleSocket(us.Config.Address)
+	}
+
+	conn, err := net.ListenPacket(network, us.Config.Address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen on %s://%s", network, us.Config.Address)
+	}
+
+	return conn, nil
+}
+
+func (us *UDPServer) read(ctx, handleCtx context.Context, conn net.PacketConn) error {
+	size := us.Config.MaxPacketSize
+	if size <= 0 {
+		size = DefaultUDPMaxPacketSize
+	}
+
+	var slots chan struct{}
+	if us.Config.MaxConcurrency > 0 {
+		slots = make(chan struct{}, us.Config.MaxConcurrency)
+	}
+
+	replies := conn
+	if us.Config.WriteTimeout > time.Duration(0) {
+		replies = &deadlinePacketConn{PacketConn: conn, writeTimeout: us.Config.WriteTimeout}
+	}
+
+	// Packets are read into the same buffer, and only their bytes are
+	// copied for the handlers, so a handler doesn't hold a whole buffer.
+	buf := make([]byte, size)
+
+	for {
+		if slots != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case slots <- struct{}{}:
+			}
+		}
+
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return errors.Wrap(err, "failed to read UDP packet")
+		}
+
+		packet := bytes.Clone(buf[:n])
+		us.wg.Add(1)
+
+		go func() {
+			defer us.wg.Done()
+			defer func() {
+				if slots != nil {
+					<-slots
+				}
+			}()
+
+			us.handle(handleCtx, replies, addr, packet)
+		}()
+	}
+}
+
+func (us *UDPServer) handle(ctx context.Context, conn net.PacketConn, addr net.Addr, packet []byte) {
+	packetLog := us.log.With().Str("remote_addr", addr.String()).Logger()
+
+	start := time.Now()
+	if err := us.Handler(log.WithContext(ctx, packetLog), conn, addr, packet); err != nil {
+		packetLog.Error().Err(err).Dur("duration", time.Since(start)).Msg("Packet handler failed")
+	}
+}
+
+// Waits for the received packets to be handled if graceful stop is enabled.
+func (us *UDPServer) stop() {
+	if !us.GracefulStopEnabled {
+		return
+	}
+
+	timeout := us.GracefulStopTimeout
+	if timeout <= time.Duration(0) {
+		timeout = DefaultGracefulStopTimeout
+	}
+
+	us.log.Info().Msg("Draining received packets")
+	if !waitTimeout(&us.wg, timeout) {
+		us.log.Warn().Msgf("Graceful stop timed out after %s, closing connection", timeout)
+	}
+}
+
+// A packet connection that sets a deadline before every write.
+type deadlinePacketConn struct {
+	net.PacketConn
+
+	writeTimeout time.Duration
+}
+
+func (c *deadlinePacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if err := c.PacketConn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+		return 0, err
+	}
+
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+// Returns a connection sharing the socket of `conn`, so closing it
+// doesn't close `conn`. The connection itself is returned if it can't
+// be duplicated, see `duplicateListener`.
+func duplicatePacketConn(conn net.PacketConn) net.PacketConn {
+	filer, ok := conn.(interface{ File() (*os.File, error) })
+	if !ok {
+		return conn
+	}
+
+	file, err := filer.File()
+	if err != nil {
+		return conn
+	}
+	defer file.Close()
+
+	duplicate, err := net.FilePacketConn(file)
+	if err != nil {
+		return conn
+	}
+
+	return duplicate
+}
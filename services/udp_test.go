@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/homier/appetizer/log"
+)
+
+func runUDPServer(t *testing.T, us *UDPServer) (context.CancelFunc, <-chan error) {
+	t.Helper()
+
+	us.Config.Address = "127.0.0.1:0"
+	require.NoError(t, us.Init(log.New(io.Discard, log.FormatJSON)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	t.Cleanup(cancel)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- us.Run(ctx) }()
+
+	require.NoError(t, us.Wait(ctx))
+	return cancel, errCh
+}
+
+func echoPacket(_ context.Context, conn net.PacketConn, addr net.Addr, packet []byte) error {
+	_, err := conn.WriteTo(packet, addr)
+	return err
+}
+
+func assertPacketEcho(t *testing.T, conn net.Conn, packet string) {
+	t.Helper()
+
+	_, err := io.WriteString(conn, packet)
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+	reply := make([]byte, 64)
+	n, err := conn.Read(reply)
+	require.NoError(t, err)
+	assert.Equal(t, packet, string(reply[:n]))
+}
+
+func TestUDPServer(t *testing.T) {
+	assert.Error(t, (&UDPServer{}).Init(log.New(io.Discard, log.FormatJSON)))
+
+	us := &UDPServer{
+		Config:  UDPServerConfig{MaxConcurrency: 2, WriteTimeout: time.Second},
+		Handler: echoPacket,
+	}
+	cancel, errCh := runUDPServer(t, us)
+
+	conn, err := net.Dial("udp", us.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assertPacketEcho(t, conn, "hello")
+	assertPacketEcho(t, conn, "world")
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+func TestUDPServer_Packets(t *testing.T) {
+	received, release := make(chan string, 2), make(chan struct{})
+
+	// Packets are held by the handlers while the next ones are read.
+	us := &UDPServer{
+		Handler: func(_ context.Context, _ net.PacketConn, _ net.Addr, packet []byte) error {
+			received <- ""
+			<-release
+			received <- string(packet)
+
+			return nil
+		},
+	}
+	cancel, errCh := runUDPServer(t, us)
+
+	conn, err := net.Dial("udp", us.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	for _, packet := range []string{"first", "second"} {
+		_, err = io.WriteString(conn, packet)
+		require.NoError(t, err)
+
+		<-received
+	}
+	close(release)
+
+	assert.ElementsMatch(t, []string{"first", "second"}, []string{<-received, <-received})
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+func TestUDPServer_GracefulStop(t *testing.T) {
+	received, release := make(chan struct{}), make(chan struct{})
+
+	us := &UDPServer{
+		Handler: func(ctx context.Context, conn net.PacketConn, addr net.Addr, packet []byte) error {
+			close(received)
+
+			// The context is cancelled only once the drain times out.
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			return echoPacket(ctx, conn, addr, packet)
+		},
+		GracefulStopEnabled: true,
+		GracefulStopTimeout: time.Second,
+	}
+	cancel, errCh := runUDPServer(t, us)
+
+	conn, err := net.Dial("udp", us.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, "bye")
+	require.NoError(t, err)
+
+	<-received
+	cancel()
+	close(release)
+
+	// The reply is sent after the server has been asked to stop.
+	reply := make([]byte, 64)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+	n, err := conn.Read(reply)
+	require.NoError(t, err)
+	assert.Equal(t, "bye", string(reply[:n]))
+
+	assert.NoError(t, <-errCh)
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"math"
+	"os"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/homier/appetizer"
+	"github.com/homier/appetizer/log"
+)
+
+// Commonly watched runtime/metrics names.
+const (
+	// Heap size occupied by objects, in bytes.
+	WatchdogMetricHeap = metricHeapObjects
+
+	// Number of live goroutines.
+	WatchdogMetricGoroutines = "/sched/goroutines:goroutines"
+
+	// The longest GC stop-the-world pause since the previous check, in seconds.
+	WatchdogMetricGCPause = "/gc/pauses:seconds"
+)
+
+// An action taken by the Watchdog when a threshold is crossed.
+type WatchdogAction string
+
+const (
+	// Logs a warning. It's done for every other action too.
+	WatchdogActionLog WatchdogAction = "log"
+
+	// Dumps profiles with `Watchdog.Dumper`.
+	WatchdogActionDump WatchdogAction = "dump"
+
+	// Restarts `WatchdogRule.Service`, see `appetizer.App.RestartService`.
+	WatchdogActionRestart WatchdogAction = "restart"
+
+	// Stops the application gracefully, see `appetizer.App.Shutdown`.
+	WatchdogActionShutdown WatchdogAction = "shutdown"
+)
+
+var (
+	DefaultWatchdogInterval         = time.Second * 5
+	DefaultWatchdogMemoryLimitRatio = 0.9
+
+	// Files with the memory limit of the process cgroup, cgroup v2 first.
+	CgroupMemoryLimitFiles = []string{
+		"/sys/fs/cgroup/memory.max",
+		"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+	}
+)
+
+// A Watchdog threshold.
+type WatchdogRule struct {
+	// A runtime/metrics name, see `WatchdogMetric*`. Metrics of uint64,
+	// float64 and histogram kinds are supported, a histogram value is
+	// the highest one observed since the previous check.
+	Metric string
+
+	// The rule is triggered when the metric value exceeds the threshold.
+	// Once triggered, it's triggered again only after the value gets
+	// back under the threshold and exceeds it again.
+	Threshold float64
+
+	// If empty, the `WatchdogActionLog` will be used.
+	Action WatchdogAction
+
+	// A service to restart, if the action is `WatchdogActionRestart`.
+	Service string
+}
+
+// An application controlled by the Watchdog.
+// It's implemented by *appetizer.App.
+type WatchdogApp interface {
+	RestartService(name string) error
+	Shutdown()
+}
+
+// A servicer that samples runtime/metrics every `Interval`
+// and acts when thresholds of `Rules` are crossed, e.g. when
+// a service leaks memory or goroutines.
+type Watchdog struct {
+	Rules []WatchdogRule
+
+	// An application to restart services of, or to shut down.
+	// Required by the `WatchdogActionRestart` and `WatchdogActionShutdown` rules.
+	App WatchdogApp
+
+	// A dumper to write profiles with.
+	// Required by the `WatchdogActionDump` rules.
+	Dumper *ProfileDumper
+
+	// How often to sample metrics.
+	// If zero, the `DefaultWatchdogInterval` will be used.
+	Interval time.Duration
+
+	// Whether to set the Go memory limit from the cgroup memory limit,
+	// see runtime/debug.SetMemoryLimit. It's skipped if the GOMEMLIMIT
+	// environment variable is set, or there is no cgroup limit.
+	MemoryLimitEnabled bool
+
+	// A part of the cgroup memory limit to set as the Go memory limit,
+	// leaving room for non-Go memory.
+	// If zero, the `DefaultWatchdogMemoryLimitRatio` will be used.
+	MemoryLimitRatio float64
+
+	ready appetizer.Waiter
+	log   log.Logger
+}
+
+// Initializes Watchdog instance, validating its configuration.
+func (wd *Watchdog) Init(log log.Logger) error {
+	wd.log = log
+
+	if wd.MemoryLimitRatio < 0 || wd.MemoryLimitRatio > 1 {
+		return errors.New("watchdog memory limit ratio must be within (0, 1]")
+	}
+
+	descriptions := make(map[string]metrics.Description)
+	for _, description := range metrics.All() {
+		descriptions[description.Name] = description
+	}
+
+	for i, rule := range wd.Rules {
+		description, ok := descriptions[rule.Metric]
+		if !ok {
+			return errors.Errorf("watchdog rule #%d: unknown metric '%s'", i, rule.Metric)
+		}
+
+		if description.Kind == metrics.KindBad {
+			return errors.Errorf("watchdog rule #%d: unsupported metric '%s'", i, rule.Metric)
+		}
+
+		switch rule.Action {
+		case "", WatchdogActionLog:
+		case WatchdogActionDump:
+			if wd.Dumper == nil {
+				return errors.Errorf("watchdog rule #%d: profile dumper is not defined", i)
+			}
+		case WatchdogActionRestart:
+			if rule.Service == "" {
+				return errors.Errorf("watchdog rule #%d: service to restart is not defined", i)
+			}
+
+			fallthrough
+		case WatchdogActionShutdown:
+			if wd.App == nil {
+				return errors.Errorf("watchdog rule #%d: application is not defined", i)
+			}
+		default:
+			return errors.Errorf("watchdog rule #%d: unknown action '%s'", i, rule.Action)
+		}
+	}
+
+	return nil
+}
+
+// Blocks until the watchdog is watching the metrics,
+// or the provided context is either cancelled or timed out.
+// If context is cancelled/timed out, a context error is returning,
+// otherwise no error is returning.
+func (wd *Watchdog) Wait(ctx context.Context) error {
+	return wd.ready.Wait(ctx)
+}
+
+// Returns a channel, that will be closed when the watchdog is watching the metrics.
+func (wd *Watchdog) WaitCh() <-chan struct{} {
+	return wd.ready.WaitCh()
+}
+
+// Samples the metrics every interval until the context is done.
+func (wd *Watchdog) Run(ctx context.Context) error {
+	if wd.MemoryLimitEnabled {
+		wd.setMemoryLimit()
+	}
+
+	interval := wd.Interval
+	if interval <= time.Duration(0) {
+		interval = DefaultWatchdogInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	samples := make([]metrics.Sample, len(wd.Rules))
+	for i, rule := range wd.Rules {
+		samples[i].Name = rule.Metric
+	}
+
+	// Histograms are cumulative, so their previous samples are kept
+	// to find values observed since the previous check.
+	previous := make([]*metrics.Float64Histogram, len(wd.Rules))
+	triggered := make([]bool, len(wd.Rules))
+
+	metrics.Read(samples)
+	for i := range samples {
+		previous[i] = copyHistogram(samples[i].Value)
+	}
+
+	wd.ready.Set(true)
+	defer wd.ready.Set(false)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		metrics.Read(samples)
+
+		for i, rule := range wd.Rules {
+			value := sampleValue(samples[i].Value, previous[i])
+			previous[i] = copyHistogram(samples[i].Value)
+
+			if value <= rule.Threshold {
+				triggered[i] = false
+				continue
+			}
+
+			if triggered[i] {
+				continue
+			}
+
+			triggered[i] = true
+			wd.act(ctx, rule, value)
+		}
+	}
+}
+
+func (wd *Watchdog) act(ctx context.Context, rule WatchdogRule, value float64) {
+	action := rule.Action
+	if action == "" {
+		action = WatchdogActionLog
+	}
+
+	ruleLog := wd.log.With().
+		Str("metric", rule.Metric).
+		Float64("value", value).
+		Float64("threshold", rule.Threshold).
+		Str("action", string(action)).
+		Logger()
+
+	ruleLog.Warn().Msg("Watchdog threshold exceeded")
+
+	switch action {
+	case WatchdogActionDump:
+		if _, err := wd.Dumper.Dump(ctx, "watchdog"); err != nil {
+			ruleLog.Error().Err(err).Msg("Failed to dump profiles")
+		}
+	case WatchdogActionRestart:
+		if err := wd.App.RestartService(rule.Service); err != nil {
+			ruleLog.Error().Err(err).Msgf("Failed to restart service '%s'", rule.Service)
+		}
+	case WatchdogActionShutdown:
+		wd.App.Shutdown()
+	}
+}
+
+// Sets the Go memory limit to a part of the cgroup memory limit.
+func (wd *Watchdog) setMemoryLimit() {
+	if os.Getenv("GOMEMLIMIT") != "" {
+		wd.log.Debug().Msg("GOMEMLIMIT is set, memory limit is left intact")
+		return
+	}
+
+	limit, ok := CgroupMemoryLimit()
+	if !ok {
+		wd.log.Debug().Msg("No cgroup memory limit found, memory limit is left intact")
+		return
+	}
+
+	ratio := wd.MemoryLimitRatio
+	if ratio == 0 {
+		ratio = DefaultWatchdogMemoryLimitRatio
+	}
+
+	memoryLimit := int64(float64(limit) * ratio)
+	debug.SetMemoryLimit(memoryLimit)
+
+	wd.log.Info().Uint64("cgroup_limit", limit).Int64("memory_limit", memoryLimit).Msg("Memory limit set")
+}
+
+// Returns the memory limit of the process cgroup in bytes, if any.
+// See `CgroupMemoryLimitFiles`.
+func CgroupMemoryLimit() (uint64, bool) {
+	for _, path := range CgroupMemoryLimitFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		value := strings.TrimSpace(string(data))
+		if value == "max" {
+			return 0, false
+		}
+
+		limit, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		// cgroup v1 reports a huge page-aligned number if there is no limit.
+		if limit >= math.MaxInt64/2 {
+			return 0, false
+		}
+
+		return limit, true
+	}
+
+	return 0, false
+}
+
+// Returns the metric value, see `highestBucket` for histograms.
+func sampleValue(value metrics.Value, previous *metrics.Float64Histogram) float64 {
+	switch value.Kind() {
+	case metrics.KindUint64:
+		return float64(value.Uint64())
+	case metrics.KindFloat64:
+		return value.Float64()
+	case metrics.KindFloat64Histogram:
+		return highestBucket(value.Float64Histogram(), previous)
+	}
+
+	return 0
+}
+
+// Returns the upper bound of the highest histogram bucket,
+// that got new observations since `previous`.
+func highestBucket(histogram, previous *metrics.Float64Histogram) float64 {
+	for i := len(histogram.Counts) - 1; i >= 0; i-- {
+		count := histogram.Counts[i]
+		if previous != nil && i < len(previous.Counts) {
+			count -= previous.Counts[i]
+		}
+
+		if count == 0 {
+			continue
+		}
+
+		// Buckets[i+1] is the upper bound, which is +Inf for the last bucket.
+		if math.IsInf(histogram.Buckets[i+1], 1) {
+			return histogram.Buckets[i]
+		}
+
+		return histogram.Buckets[i+1]
+	}
+
+	return 0
+}
+
+func copyHistogram(value metrics.Value) *metrics.Float64Histogram {
+	if value.Kind() != metrics.KindFloat64Histogram {
+		return nil
+	}
+
+	histogram := value.Float64Histogram()
+	return &metrics.Float64Histogram{
+		Counts:  append([]uint64(nil), histogram.Counts...),
+		Buckets: histogram.Buckets,
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"runtime/metrics"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/homier/appetizer"
+	"github.com/homier/appetizer/log"
+)
+
+func TestWatchdog_Init(t *testing.T) {
+	tests := []struct {
+		name     string
+		watchdog *Watchdog
+		err      string
+	}{
+		{name: "valid", watchdog: &Watchdog{Rules: []WatchdogRule{{Metric: WatchdogMetricHeap, Threshold: 1}}}},
+		{
+			name:     "unknown metric",
+			watchdog: &Watchdog{Rules: []WatchdogRule{{Metric: "/unknown:bytes"}}},
+			err:      "watchdog rule #0: unknown metric '/unknown:bytes'",
+		},
+		{
+			name:     "unknown action",
+			watchdog: &Watchdog{Rules: []WatchdogRule{{Metric: WatchdogMetricHeap, Action: "panic"}}},
+			err:      "unknown action 'panic'",
+		},
+		{
+			name:     "dump without dumper",
+			watchdog: &Watchdog{Rules: []WatchdogRule{{Metric: WatchdogMetricHeap, Action: WatchdogActionDump}}},
+			err:      "profile dumper is not defined",
+		},
+		{
+			name:     "restart without service",
+			watchdog: &Watchdog{Rules: []WatchdogRule{{Metric: WatchdogMetricHeap, Action: WatchdogActionRestart}}},
+			err:      "service to restart is not defined",
+		},
+		{
+			name:     "shutdown without app",
+			watchdog: &Watchdog{Rules: []WatchdogRule{{Metric: WatchdogMetricHeap, Action: WatchdogActionShutdown}}},
+			err:      "application is not defined",
+		},
+		{
+			name:     "invalid memory limit ratio",
+			watchdog: &Watchdog{MemoryLimitRatio: 1.5},
+			err:      "memory limit ratio",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.watchdog.Init(log.New(io.Discard, log.FormatJSON))
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+}
+
+type watchdogApp struct {
+	restarts chan string
+	shutdown chan struct{}
+}
+
+func (a *watchdogApp) RestartService(name string) error {
+	a.restarts <- name
+	return nil
+}
+
+func (a *watchdogApp) Shutdown() {
+	close(a.shutdown)
+}
+
+func TestWatchdog_Run(t *testing.T) {
+	buf := &syncBuffer{}
+	dumper := &ProfileDumper{Dir: t.TempDir(), Profiles: []string{"goroutine"}}
+	app := &watchdogApp{restarts: make(chan string, 1), shutdown: make(chan struct{})}
+
+	wd := &Watchdog{
+		Rules: []WatchdogRule{
+			{Metric: WatchdogMetricGoroutines, Threshold: 1},
+			{Metric: WatchdogMetricGoroutines, Threshold: 1, Action: WatchdogActionDump},
+			{Metric: WatchdogMetricHeap, Threshold: 1, Action: WatchdogActionRestart, Service: "leaky"},
+			{Metric: WatchdogMetricGoroutines, Threshold: 1, Action: WatchdogActionShutdown},
+			{Metric: WatchdogMetricGoroutines, Threshold: 1e9, Action: WatchdogActionShutdown},
+		},
+		App:      app,
+		Dumper:   dumper,
+		Interval: time.Millisecond * 10,
+	}
+	require.NoError(t, wd.Init(log.New(buf, log.FormatJSON)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- wd.Run(ctx) }()
+
+	select {
+	case <-app.shutdown:
+	case <-ctx.Done():
+		t.Fatal("shutdown wasn't requested")
+	}
+
+	assert.Equal(t, "leaky", <-app.restarts)
+
+	paths, err := filepath.Glob(filepath.Join(dumper.Dir, "goroutine-*-watchdog.pprof"))
+	require.NoError(t, err)
+	assert.Len(t, paths, 1)
+
+	// Triggered rules aren't triggered again while the value stays high.
+	time.Sleep(time.Millisecond * 50)
+	assert.Len(t, app.restarts, 0)
+
+	cancel()
+	assert.NoError(t, <-errCh)
+
+	assert.Equal(t, 4, strings.Count(buf.String(), "Watchdog threshold exceeded"))
+	assert.Contains(t, buf.String(), `"metric":"/sched/goroutines:goroutines"`)
+}
+
+func TestWatchdog_MemoryLimit(t *testing.T) {
+	previous := debug.SetMemoryLimit(-1)
+	defer debug.SetMemoryLimit(previous)
+
+	files := CgroupMemoryLimitFiles
+	defer func() { CgroupMemoryLimitFiles = files }()
+
+	dir := t.TempDir()
+	limitFile := filepath.Join(dir, "memory.max")
+	CgroupMemoryLimitFiles = []string{filepath.Join(dir, "missing"), limitFile}
+
+	tests := []struct {
+		name    string
+		content string
+		limit   uint64
+		ok      bool
+	}{
+		{name: "limit", content: "1073741824\n", limit: 1 << 30, ok: true},
+		{name: "cgroup v2 no limit", content: "max\n"},
+		{name: "cgroup v1 no limit", content: "9223372036854771712\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(limitFile, []byte(tt.content), 0o644))
+
+			limit, ok := CgroupMemoryLimit()
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.limit, limit)
+		})
+	}
+
+	require.NoError(t, os.WriteFile(limitFile, []byte("1073741824\n"), 0o644))
+	t.Setenv("GOMEMLIMIT", "")
+
+	wd := &Watchdog{MemoryLimitEnabled: true, MemoryLimitRatio: 0.5}
+	require.NoError(t, wd.Init(log.New(io.Discard, log.FormatJSON)))
+
+	wd.setMemoryLimit()
+	assert.Equal(t, int64(1<<29), debug.SetMemoryLimit(-1))
+}
+
+func TestSampleValue(t *testing.T) {
+	previous := &metrics.Float64Histogram{Counts: []uint64{1, 2, 0}, Buckets: []float64{0, 1, 2, 3}}
+	current := &metrics.Float64Histogram{Counts: []uint64{3, 2, 0}, Buckets: []float64{0, 1, 2, 3}}
+
+	samples := []metrics.Sample{{Name: WatchdogMetricGCPause}}
+	metrics.Read(samples)
+
+	// Nothing new since the same sample.
+	assert.Equal(t, 0.0, sampleValue(samples[0].Value, copyHistogram(samples[0].Value)))
+
+	assert.Equal(t, 1.0, highestBucket(current, previous))
+	assert.Equal(t, 2.0, highestBucket(current, nil))
+}
+
+var _ WatchdogApp = (*appetizer.App)(nil)
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/homier/appetizer"
+	"github.com/homier/appetizer/log"
+)
+
+var (
+	DefaultFileWatcherDebounce     = time.Millisecond * 100
+	DefaultFileWatcherPollInterval = time.Second * 5
+)
+
+// A servicer that watches files and calls `OnChange` when they change.
+// It relies on file system notifications where supported (inotify on Linux),
+// and polls the files otherwise. Either way, a file is considered changed
+// when it appears, disappears, or its size, modification time or identity
+// changes, so atomic replacements, e.g. by Kubernetes, are detected too.
+type FileWatcher struct {
+	// File paths or glob patterns, see path/filepath.Match for the syntax.
+	// Files matching a pattern are picked up when they appear.
+	Paths []string
+
+	// Called with the sorted paths of changed files.
+	// An error is logged, and doesn't stop the watcher.
+	OnChange func(ctx context.Context, paths []string) error
+
+	// Changes are reported once there were no file system events for this long,
+	// so a file being written is reported once.
+	// If zero, the `DefaultFileWatcherDebounce` will be used.
+	Debounce time.Duration
+
+	// Changes are reported at the latest this long after the first event,
+	// even if events keep coming, so a busy file doesn't delay them forever.
+	// If zero, ten times the debounce period will be used.
+	MaxWait time.Duration
+
+	// Whether to always poll the files instead of using notifications.
+	PollingEnabled bool
+
+	// How often to poll the files, if polling is used.
+	// If zero, the `DefaultFileWatcherPollInterval` will be used.
+	PollInterval time.Duration
+
+	ready appetizer.Waiter
+	log   log.Logger
+}
+
+// Receives file system notifications for directories.
+// It's created with a function reporting whether a changed path is relevant,
+// other paths are ignored.
+type notifier interface {
+	// Signals that a relevant path has changed in one of the directories.
+	Events() <-chan struct{}
+
+	// Starts watching the directories. Watching a directory again is a no-op.
+	Add(dirs []string) error
+
+	Close() error
+}
+
+// Initializes FileWatcher instance, validating its configuration.
+func (fw *FileWatcher) Init(log log.Logger) error {
+	fw.log = log
+
+	if len(fw.Paths) == 0 {
+		return errors.New("file watcher paths are not defined")
+	}
+
+	if fw.OnChange == nil {
+		return errors.New("file watcher callback is not defined")
+	}
+
+	for _, pattern := range fw.Paths {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid file watcher pattern '%s'", pattern)
+		}
+	}
+
+	return nil
+}
+
+// Blocks until the watcher is watching the files,
+// or the provided context is either cancelled or timed out.
+// If context is cancelled/timed out, a context error is returning,
+// otherwise no error is returning.
+func (fw *FileWatcher) Wait(ctx context.Context) error {
+	return fw.ready.Wait(ctx)
+}
+
+// Returns a channel, that will be closed when the watcher is watching the files.
+func (fw *FileWatcher) WaitCh() <-chan struct{} {
+	return fw.ready.WaitCh()
+}
+
+// Watches the files until the context is done.
+func (fw *FileWatcher) Run(ctx context.Context) error {
+	debounce := fw.Debounce
+	if debounce <= time.Duration(0) {
+		debounce = DefaultFileWatcherDebounce
+	}
+
+	maxWait := fw.MaxWait
+	if maxWait <= time.Duration(0) {
+		maxWait = debounce * 10
+	}
+
+	// Symlinks the watched files are resolved through, updated on every scan.
+	var links atomic.Pointer[map[string]struct{}]
+	links.Store(&map[string]struct{}{})
+
+	relevant := func(path string) bool {
+		return fw.watched(path, *links.Load())
+	}
+
+	var events <-chan struct{}
+	var notifications notifier
+
+	if !fw.PollingEnabled {
+		n, err := newNotifier(relevant)
+		if err == nil {
+			if err = n.Add(fw.dirs()); err != nil {
+				_ = n.Close()
+			}
+		}
+
+		if err != nil {
+			fw.log.Warn().Err(err).Msg("File system notifications are unavailable, polling files")
+		} else {
+			defer n.Close()
+
+			notifications, events = n, n.Events()
+		}
+	}
+
+	var poll <-chan time.Time
+	if notifications == nil {
+		interval := fw.PollInterval
+		if interval <= time.Duration(0) {
+			interval = DefaultFileWatcherPollInterval
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		poll = ticker.C
+	}
+
+	files := fw.scan()
+	links.Store(resolvedSymlinks(files))
+
+	fw.ready.Set(true)
+	defer fw.ready.Set(false)
+
+	debounceTimer := time.NewTimer(debounce)
+	debounceTimer.Stop()
+	defer debounceTimer.Stop()
+
+	// When the pending changes must be reported, zero if there are none.
+	var deadline time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-events:
+			now := time.Now()
+			if deadline.IsZero() {
+				deadline = now.Add(maxWait)
+			}
+
+			debounceTimer.Reset(min(debounce, deadline.Sub(now)))
+			continue
+		case <-debounceTimer.C:
+			deadline = time.Time{}
+
+			// Directories could have been created since the last time.
+			if err := notifications.Add(fw.dirs()); err != nil {
+				fw.log.Debug().Err(err).Msg("Failed to watch some directories")
+			}
+		case <-poll:
+		}
+
+		var changed []string
+		files, changed = fw.rescan(files)
+		links.Store(resolvedSymlinks(files))
+
+		if len(changed) == 0 {
+			continue
+		}
+
+		fw.log.Debug().Strs("paths", changed).Msg("Files changed")
+		if err := fw.OnChange(ctx, changed); err != nil {
+			fw.log.Error().Err(err).Strs("paths", changed).Msg("Failed to handle file changes")
+		}
+	}
+}
+
+// Returns the directories of the watched files.
+func (fw *FileWatcher) dirs() []string {
+	seen := make(map[string]struct{}, len(fw.Paths))
+	dirs := make([]string, 0, len(fw.Paths))
+
+	for _, pattern := range fw.Paths {
+		// The directory part might be a pattern too.
+		matches, _ := filepath.Glob(filepath.Dir(pattern))
+		for _, dir := range matches {
+			if _, ok := seen[dir]; !ok {
+				seen[dir] = struct{}{}
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+
+	return dirs
+}
+
+// Reports whether a changed path could change the watched files:
+// it matches a pattern, or is a symlink the files are resolved through,
+// e.g. the "..data" one of Kubernetes volumes.
+func (fw *FileWatcher) watched(path string, links map[string]struct{}) bool {
+	path = filepath.Clean(path)
+	if _, ok := links[path]; ok {
+		return true
+	}
+
+	for _, pattern := range fw.Paths {
+		if ok, _ := filepath.Match(filepath.Clean(pattern), path); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the symlinks the files are resolved through.
+func resolvedSymlinks(files map[string]os.FileInfo) *map[string]struct{} {
+	links := make(map[string]struct{})
+
+	for path := range files {
+		path = filepath.Clean(path)
+
+		// Limits the number of hops, like the kernel does.
+		for hops := 0; hops < 40; hops++ {
+			link, resolved, ok := resolveSymlink(path)
+			if !ok {
+				break
+			}
+
+			links[link] = struct{}{}
+			path = resolved
+		}
+	}
+
+	return &links
+}
+
+// Replaces the first symlink among the path components with its target,
+// returning the symlink and the resulting path.
+func resolveSymlink(path string) (string, string, bool) {
+	for i := 1; i <= len(path); i++ {
+		if i < len(path) && path[i] != filepath.Separator {
+			continue
+		}
+
+		prefix := path[:i]
+
+		info, err := os.Lstat(prefix)
+		if err != nil {
+			return "", "", false
+		}
+
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		target, err := os.Readlink(prefix)
+		if err != nil {
+			return "", "", false
+		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(prefix), target)
+		}
+
+		return prefix, filepath.Join(target, path[i:]), true
+	}
+
+	return "", "", false
+}
+
+// Returns the watched files by their paths.
+func (fw *FileWatcher) scan() map[string]os.FileInfo {
+	files := make(map[string]os.FileInfo, len(fw.Paths))
+
+	for _, pattern := range fw.Paths {
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			// Follows symlinks, so a replaced target is noticed.
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				files[path] = info
+			}
+		}
+	}
+
+	return files
+}
+
+// Scans the files again, returning them and the sorted changed paths.
+func (fw *FileWatcher) rescan(previous map[string]os.FileInfo) (map[string]os.FileInfo, []string) {
+	current := fw.scan()
+	changed := make([]string, 0)
+
+	for path, info := range current {
+		prev, ok := previous[path]
+		if !ok || !os.SameFile(prev, info) || !prev.ModTime().Equal(info.ModTime()) || prev.Size() != info.Size() {
+			changed = append(changed, path)
+		}
+	}
+
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+
+	sort.Strings(changed)
+	return current, changed
+}
@@ -0,0 +1,128 @@
+//go:build linux
+
+package services
+
+import (
+	"encoding/binary"
+	stdErrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ATTRIB | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// An inotify based notifier.
+type inotify struct {
+	// Kept aside, since os.File.Fd switches the file to the blocking mode.
+	fd     int
+	file   *os.File
+	events chan struct{}
+
+	relevant func(path string) bool
+
+	// Watched directories by their watch descriptors.
+	dirs map[int32]string
+	mu   sync.Mutex
+}
+
+func newNotifier(relevant func(path string) bool) (notifier, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize inotify")
+	}
+
+	// A non-blocking descriptor is served by the runtime poller,
+	// so closing the file interrupts a pending read.
+	n := &inotify{
+		fd:       fd,
+		file:     os.NewFile(uintptr(fd), "inotify"),
+		events:   make(chan struct{}, 1),
+		relevant: relevant,
+		dirs:     make(map[int32]string),
+	}
+	go n.read()
+
+	return n, nil
+}
+
+func (n *inotify) Events() <-chan struct{} {
+	return n.events
+}
+
+// Watches the directories. Adding a watched directory again is a no-op,
+// while a recreated one gets a new watch.
+func (n *inotify) Add(dirs []string) (errs error) {
+	for _, dir := range dirs {
+		wd, err := unix.InotifyAddWatch(n.fd, dir, inotifyMask)
+		if err != nil {
+			errs = stdErrors.Join(errs, errors.Wrapf(err, "failed to watch '%s'", dir))
+			continue
+		}
+
+		n.mu.Lock()
+		n.dirs[int32(wd)] = dir
+		n.mu.Unlock()
+	}
+
+	return errs
+}
+
+func (n *inotify) Close() error {
+	return n.file.Close()
+}
+
+// Signals on every read events batch with a relevant event.
+// Only the event paths are parsed, since the watched files are compared on rescan anyway.
+func (n *inotify) read() {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+
+	for {
+		size, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		if !n.hasRelevant(buf[:size]) {
+			continue
+		}
+
+		select {
+		case n.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Reports whether any of the events is relevant. Queue overflows, events
+// of unknown watches and of the watched directories themselves always are.
+func (n *inotify) hasRelevant(buf []byte) bool {
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		wd := int32(binary.NativeEndian.Uint32(buf[offset:]))
+		mask := binary.NativeEndian.Uint32(buf[offset+4:])
+		nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+
+		nameStart := offset + unix.SizeofInotifyEvent
+		offset = min(nameStart+nameLen, len(buf))
+
+		if mask&unix.IN_Q_OVERFLOW != 0 {
+			return true
+		}
+
+		n.mu.Lock()
+		dir, ok := n.dirs[wd]
+		n.mu.Unlock()
+
+		name := strings.TrimRight(string(buf[nameStart:offset]), "\x00")
+		if !ok || name == "" || n.relevant(filepath.Join(dir, name)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package services
+
+import "github.com/pkg/errors"
+
+func newNotifier(func(path string) bool) (notifier, error) {
+	return nil, errors.New("file system notifications are not supported on this platform")
+}
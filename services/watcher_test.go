@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/homier/appetizer/log"
+)
+
+func TestFileWatcher_Init(t *testing.T) {
+	logger := log.New(io.Discard, log.FormatJSON)
+	onChange := func(context.Context, []string) error { return nil }
+
+	assert.Error(t, (&FileWatcher{OnChange: onChange}).Init(logger))
+	assert.Error(t, (&FileWatcher{Paths: []string{"config.yaml"}}).Init(logger))
+	assert.Error(t, (&FileWatcher{Paths: []string{"[config.yaml"}, OnChange: onChange}).Init(logger))
+	assert.NoError(t, (&FileWatcher{Paths: []string{"*.yaml"}, OnChange: onChange}).Init(logger))
+}
+
+func TestFileWatcher_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		polling bool
+	}{
+		{name: "Notifications"},
+		{name: "Polling", polling: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			config := filepath.Join(dir, "config.json")
+			require.NoError(t, os.WriteFile(config, []byte("{}"), 0o600))
+
+			changes := make(chan []string, 10)
+			fw := &FileWatcher{
+				Paths: []string{config, filepath.Join(dir, "*.yaml")},
+				OnChange: func(_ context.Context, paths []string) error {
+					changes <- paths
+					return nil
+				},
+				Debounce:       time.Millisecond * 10,
+				PollingEnabled: tt.polling,
+				PollInterval:   time.Millisecond * 10,
+			}
+			require.NoError(t, fw.Init(log.New(io.Discard, log.FormatJSON)))
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+			defer cancel()
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- fw.Run(ctx) }()
+			require.NoError(t, fw.Wait(ctx))
+
+			expectChange := func(expected ...string) {
+				t.Helper()
+
+				select {
+				case paths := <-changes:
+					assert.Equal(t, expected, paths)
+				case <-ctx.Done():
+					t.Fatalf("no changes of %v reported", expected)
+				}
+			}
+
+			yaml := filepath.Join(dir, "app.yaml")
+			require.NoError(t, os.WriteFile(yaml, []byte("a: 1"), 0o600))
+			expectChange(yaml)
+
+			// An atomic replacement.
+			tmp := filepath.Join(dir, "config.json.tmp")
+			require.NoError(t, os.WriteFile(tmp, []byte(`{"a":1}`), 0o600))
+			require.NoError(t, os.Rename(tmp, config))
+			expectChange(config)
+
+			require.NoError(t, os.Remove(yaml))
+			expectChange(yaml)
+
+			cancel()
+			assert.NoError(t, <-errCh)
+		})
+	}
+}
+
+func TestFileWatcher_Events(t *testing.T) {
+	// Runs a watcher of the config file in `dir`, returning its changes.
+	watch := func(t *testing.T, dir string, maxWait time.Duration) <-chan []string {
+		t.Helper()
+
+		changes := make(chan []string, 10)
+		fw := &FileWatcher{
+			Paths: []string{filepath.Join(dir, "config.json")},
+			OnChange: func(_ context.Context, paths []string) error {
+				changes <- paths
+				return nil
+			},
+			Debounce: time.Millisecond * 50,
+			MaxWait:  maxWait,
+
+			// Notifications are unavailable on some platforms.
+			PollInterval: time.Millisecond * 10,
+		}
+		require.NoError(t, fw.Init(log.New(io.Discard, log.FormatJSON)))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 1)
+		go func() { errCh <- fw.Run(ctx) }()
+
+		t.Cleanup(func() {
+			cancel()
+			assert.NoError(t, <-errCh)
+		})
+
+		require.NoError(t, fw.Wait(ctx))
+		return changes
+	}
+
+	// Writes the file until the test ends.
+	writeRepeatedly := func(t *testing.T, path string) {
+		t.Helper()
+
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		t.Cleanup(func() {
+			close(done)
+			<-stopped
+		})
+
+		go func() {
+			defer close(stopped)
+
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				case <-time.After(time.Millisecond * 5):
+				}
+
+				_ = os.WriteFile(path, []byte(strconv.Itoa(i)), 0o600)
+			}
+		}()
+	}
+
+	expectChange := func(t *testing.T, changes <-chan []string, expected string) {
+		t.Helper()
+
+		select {
+		case paths := <-changes:
+			assert.Equal(t, []string{expected}, paths)
+		case <-time.After(time.Second):
+			t.Fatalf("no changes of %s reported", expected)
+		}
+	}
+
+	t.Run("Unwatched files are ignored", func(t *testing.T) {
+		dir := t.TempDir()
+		config := filepath.Join(dir, "config.json")
+
+		changes := watch(t, dir, time.Minute)
+		writeRepeatedly(t, filepath.Join(dir, "other.json"))
+
+		require.NoError(t, os.WriteFile(config, []byte("{}"), 0o600))
+		expectChange(t, changes, config)
+	})
+
+	t.Run("Busy files are reported after max wait", func(t *testing.T) {
+		dir := t.TempDir()
+		config := filepath.Join(dir, "config.json")
+
+		changes := watch(t, dir, time.Millisecond*100)
+		writeRepeatedly(t, config)
+
+		expectChange(t, changes, config)
+	})
+
+	t.Run("Symlinks are followed", func(t *testing.T) {
+		dir := t.TempDir()
+		config := filepath.Join(dir, "config.json")
+
+		// A Kubernetes volume layout, updated by swapping the "..data" symlink.
+		for _, version := range []string{"..v1", "..v2"} {
+			require.NoError(t, os.Mkdir(filepath.Join(dir, version), 0o700))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, version, "config.json"), []byte(version), 0o600))
+		}
+		require.NoError(t, os.Symlink("..v1", filepath.Join(dir, "..data")))
+		require.NoError(t, os.Symlink(filepath.Join("..data", "config.json"), config))
+
+		changes := watch(t, dir, time.Minute)
+
+		require.NoError(t, os.Symlink("..v2", filepath.Join(dir, "..data_tmp")))
+		require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+		expectChange(t, changes, config)
+	})
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/homier/appetizer/log"
+	"github.com/homier/appetizer/retry"
+)
+
+var (
+	// Returned by a `Source` when there are no more items.
+	ErrSourceClosed = errors.New("source is closed")
+
+	DefaultWorkerPoolConcurrency = 1
+	DefaultDrainTimeout          = time.Second * 5
+)
+
+// A source of items to process by a `WorkerPool`.
+type Source[T any] interface {
+	// Blocks until the next item is available, returning it.
+	// Returns `ErrSourceClosed` if there are no more items,
+	// or a context error if the context is done.
+	Next(ctx context.Context) (T, error)
+}
+
+// A function that implements `Source`.
+type SourceFunc[T any] func(ctx context.Context) (T, error)
+
+// Next implements Source.
+func (f SourceFunc[T]) Next(ctx context.Context) (T, error) {
+	return f(ctx)
+}
+
+// Returns a source that reads items from `ch` until it's closed.
+// The number of buffered items is reported as queued, see `WorkerPool.Stats`.
+func ChanSource[T any](ch <-chan T) Source[T] {
+	return chanSource[T]{ch: ch}
+}
+
+type chanSource[T any] struct {
+	ch <-chan T
+}
+
+func (s chanSource[T]) Next(ctx context.Context) (T, error) {
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	case item, ok := <-s.ch:
+		if !ok {
+			return item, ErrSourceClosed
+		}
+
+		return item, nil
+	}
+}
+
+func (s chanSource[T]) Len() int {
+	return len(s.ch)
+}
+
+// Worker pool statistics.
+type WorkerPoolStats struct {
+	// Number of items being handled right now.
+	InFlight int64
+
+	// Number of items waiting in the source.
+	// Zero if the source doesn't report it with a `Len() int` method.
+	Queued int
+
+	// Number of handled items, including the failed ones.
+	Processed uint64
+
+	// Number of items that failed to be handled, retries included.
+	Failed uint64
+}
+
+// A servicer that takes items from `Source` and handles them concurrently.
+// The servicer stops once the source is closed and all items are handled.
+type WorkerPool[T any] struct {
+	Source Source[T]
+
+	// Handles an item. An error is logged, and the item is retried
+	// if `RetryOpts.Opts` is defined. The handler context isn't cancelled
+	// on shutdown until the drain timeout is reached.
+	Handle func(ctx context.Context, item T) error
+
+	// Number of items handled concurrently.
+	// If zero, the `DefaultWorkerPoolConcurrency` will be used.
+	Concurrency int
+
+	// A retry policy of a failed item. If `RetryOpts.Opts` is nil,
+	// failed items are not retried.
+	RetryOpts retry.Opts
+
+	// On shutdown, no more items are taken from the source, and the items
+	// in flight are given this long to be handled before their context
+	// is cancelled. If zero, the `DefaultDrainTimeout` will be used.
+	DrainTimeout time.Duration
+
+	inFlight  atomic.Int64
+	processed atomic.Uint64
+	failed    atomic.Uint64
+
+	log log.Logger
+}
+
+// Initializes WorkerPool instance, validating its configuration.
+func (wp *WorkerPool[T]) Init(log log.Logger) error {
+	wp.log = log
+
+	if wp.Source == nil {
+		return errors.New("worker pool source is not defined")
+	}
+
+	if wp.Handle == nil {
+		return errors.New("worker pool handler is not defined")
+	}
+
+	return nil
+}
+
+// Returns the current worker pool statistics.
+func (wp *WorkerPool[T]) Stats() WorkerPoolStats {
+	stats := WorkerPoolStats{
+		InFlight:  wp.inFlight.Load(),
+		Processed: wp.processed.Load(),
+		Failed:    wp.failed.Load(),
+	}
+
+	if source, ok := wp.Source.(interface{ Len() int }); ok {
+		stats.Queued = source.Len()
+	}
+
+	return stats
+}
+
+// Handles items until the source is closed or the context is done.
+// On context cancellation, the items in flight are drained, see `DrainTimeout`.
+// Returns a source error, if any.
+func (wp *WorkerPool[T]) Run(ctx context.Context) error {
+	concurrency := wp.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultWorkerPoolConcurrency
+	}
+
+	// Items in flight are handled with their own context,
+	// so they aren't interrupted right on shutdown.
+	handleCtx, cancelHandle := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelHandle()
+
+	nextCtx, stopNext := context.WithCancel(ctx)
+	defer stopNext()
+
+	var (
+		wg      sync.WaitGroup
+		errOnce sync.Once
+		err     error
+	)
+
+	for range concurrency {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if workErr := wp.work(nextCtx, handleCtx); workErr != nil {
+				errOnce.Do(func() { err = workErr })
+				stopNext()
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	timeout := wp.DrainTimeout
+	if timeout <= time.Duration(0) {
+		timeout = DefaultDrainTimeout
+	}
+
+	wp.log.Info().Msgf("Draining %d items in flight", wp.inFlight.Load())
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		wp.log.Warn().Msgf("Drain timed out after %s, cancelling %d items in flight", timeout, wp.inFlight.Load())
+		cancelHandle()
+		<-done
+	}
+
+	return err
+}
+
+func (wp *WorkerPool[T]) work(nextCtx, handleCtx context.Context) error {
+	for {
+		item, err := wp.Source.Next(nextCtx)
+		if err != nil {
+			if nextCtx.Err() != nil || errors.Is(err, ErrSourceClosed) {
+				return nil
+			}
+
+			return errors.Wrap(err, "failed to get next item")
+		}
+
+		wp.handle(handleCtx, item)
+	}
+}
+
+func (wp *WorkerPool[T]) handle(ctx context.Context, item T) {
+	wp.inFlight.Add(1)
+	defer wp.inFlight.Add(-1)
+
+	handle := func(ctx context.Context) error {
+		err := wp.Handle(ctx, item)
+		if err != nil {
+			wp.failed.Add(1)
+			wp.log.Error().Err(err).Msg("Failed to handle item")
+		}
+
+		return err
+	}
+
+	if wp.RetryOpts.Opts != nil {
+		_ = retry.With(ctx, handle, wp.RetryOpts)
+	} else {
+		_ = handle(ctx)
+	}
+
+	wp.processed.Add(1)
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/homier/appetizer/log"
+	"github.com/homier/appetizer/retry"
+)
+
+func TestWorkerPool_Init(t *testing.T) {
+	logger := log.New(io.Discard, log.FormatJSON)
+
+	assert.Error(t, (&WorkerPool[int]{Handle: func(context.Context, int) error { return nil }}).Init(logger))
+	assert.Error(t, (&WorkerPool[int]{Source: ChanSource(make(chan int))}).Init(logger))
+}
+
+func TestWorkerPool_Run(t *testing.T) {
+	t.Run("Handles all items", func(t *testing.T) {
+		queue := make(chan int, 100)
+		for i := range 100 {
+			queue <- i
+		}
+		close(queue)
+
+		sum := atomic.Int64{}
+		wp := &WorkerPool[int]{
+			Source: ChanSource(queue),
+			Handle: func(_ context.Context, item int) error {
+				sum.Add(int64(item))
+				return nil
+			},
+			Concurrency: 4,
+		}
+		require.NoError(t, wp.Init(log.New(io.Discard, log.FormatJSON)))
+
+		assert.Equal(t, 100, wp.Stats().Queued)
+		assert.NoError(t, wp.Run(context.Background()))
+		assert.Equal(t, int64(4950), sum.Load())
+		assert.Equal(t, WorkerPoolStats{Processed: 100}, wp.Stats())
+	})
+
+	t.Run("Retries failed items", func(t *testing.T) {
+		queue := make(chan int, 1)
+		queue <- 1
+		close(queue)
+
+		attempts := atomic.Int64{}
+		wp := &WorkerPool[int]{
+			Source: ChanSource(queue),
+			Handle: func(context.Context, int) error {
+				if attempts.Add(1) < 3 {
+					return errors.New("handle error")
+				}
+
+				return nil
+			},
+			RetryOpts: retry.Opts{Opts: backoff.NewConstantBackOff(time.Millisecond), MaxRetry: 5},
+		}
+		require.NoError(t, wp.Init(log.New(io.Discard, log.FormatJSON)))
+
+		assert.NoError(t, wp.Run(context.Background()))
+		assert.Equal(t, int64(3), attempts.Load())
+		assert.Equal(t, WorkerPoolStats{Processed: 1, Failed: 2}, wp.Stats())
+	})
+
+	t.Run("Source error", func(t *testing.T) {
+		errSource := errors.New("source error")
+
+		wp := &WorkerPool[int]{
+			Source: SourceFunc[int](func(context.Context) (int, error) {
+				return 0, errSource
+			}),
+			Handle:      func(context.Context, int) error { return nil },
+			Concurrency: 2,
+		}
+		require.NoError(t, wp.Init(log.New(io.Discard, log.FormatJSON)))
+
+		assert.ErrorIs(t, wp.Run(context.Background()), errSource)
+	})
+}
+
+func TestWorkerPool_Drain(t *testing.T) {
+	tests := []struct {
+		name         string
+		drainTimeout time.Duration
+		release      bool
+		expectedErr  error
+	}{
+		{name: "Items in flight are finished", drainTimeout: time.Second, release: true},
+		{name: "Items in flight are cancelled", drainTimeout: time.Millisecond * 10, expectedErr: context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := make(chan int, 1)
+			queue <- 1
+
+			started, release := make(chan struct{}), make(chan struct{})
+			handleErr := make(chan error, 1)
+
+			wp := &WorkerPool[int]{
+				Source: ChanSource(queue),
+				Handle: func(ctx context.Context, _ int) error {
+					close(started)
+
+					select {
+					case <-release:
+					case <-ctx.Done():
+					}
+
+					handleErr <- ctx.Err()
+					return nil
+				},
+				DrainTimeout: tt.drainTimeout,
+			}
+			require.NoError(t, wp.Init(log.New(io.Discard, log.FormatJSON)))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			errCh := make(chan error, 1)
+			go func() { errCh <- wp.Run(ctx) }()
+
+			<-started
+			assert.Equal(t, int64(1), wp.Stats().InFlight)
+
+			cancel()
+			if tt.release {
+				close(release)
+			}
+
+			assert.NoError(t, <-errCh)
+			assert.Equal(t, tt.expectedErr, <-handleErr)
+			assert.Equal(t, int64(0), wp.Stats().InFlight)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package appetizer
+
+import (
+	"time"
+)
+
+// A state of the service lifecycle.
+type ServiceState string
+
+const (
+	// The service hasn't been initialized yet.
+	ServiceStatePending ServiceState = "pending"
+
+	// The service is being initialized.
+	ServiceStateInitializing ServiceState = "initializing"
+
+	// The service is initialized, but isn't running yet.
+	ServiceStateInitialized ServiceState = "initialized"
+
+	// The service is running.
+	ServiceStateRunning ServiceState = "running"
+
+	// The service has failed and is waiting to be restarted.
+	ServiceStateRestarting ServiceState = "restarting"
+
+	// The service has stopped without an error.
+	ServiceStateStopped ServiceState = "stopped"
+
+	// The service has failed, either to initialize or to run.
+	ServiceStateFailed ServiceState = "failed"
+)
+
+// A status of the service.
+type ServiceStatus struct {
+	Name  string       `json:"name"`
+	State ServiceState `json:"state"`
+
+	// When the service has entered the current state.
+	// Zero, if the service is pending.
+	Since time.Time `json:"since"`
+
+	// How many times the service has been restarted.
+	Restarts uint64 `json:"restarts"`
+
+	// The last service error, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// A status of the application.
+type AppStatus struct {
+	Name     string          `json:"name"`
+	Started  bool            `json:"started"`
+	Services []ServiceStatus `json:"services"`
+}
+
+// Returns the current status of the application and its services,
+// the services are in the `App.Services` order.
+func (a *App) Status() AppStatus {
+	status := AppStatus{
+		Name:     a.Name,
+		Started:  a.startedWaiter.Is(true),
+		Services: make([]ServiceStatus, 0, len(a.Services)),
+	}
+
+	a.statusesMu.Lock()
+	defer a.statusesMu.Unlock()
+
+	for _, service := range a.Services {
+		serviceStatus, ok := a.statuses[service.Name]
+		if !ok {
+			serviceStatus = ServiceStatus{Name: service.Name, State: ServiceStatePending}
+		}
+
+		status.Services = append(status.Services, serviceStatus)
+	}
+
+	return status
+}
+
+// Moves the service to the `state`. The error is kept unless a new one is provided.
+func (a *App) setServiceState(name string, state ServiceState, err error) {
+	defer a.notifyStatus()
+
+	a.statusesMu.Lock()
+	defer a.statusesMu.Unlock()
+
+	if a.statuses == nil {
+		a.statuses = make(map[string]ServiceStatus, len(a.Services))
+	}
+
+	status := a.statuses[name]
+	status.Name = name
+	status.State = state
+	status.Since = time.Now()
+
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	a.statuses[name] = status
+}
+
+func (a *App) countServiceRestart(name string) {
+	a.statusesMu.Lock()
+	defer a.statusesMu.Unlock()
+
+	status := a.statuses[name]
+	status.Restarts++
+
+	a.statuses[name] = status
+}
@@ -0,0 +1,81 @@
+package appetizer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/homier/appetizer/systemd"
+)
+
+// Notifies systemd about the started application, and keeps doing so
+// until the context is done: the service states are sent as STATUS,
+// the watchdog is pinged if it's enabled, and STOPPING is sent on stop.
+// It's a no-op unless the application is run as a `Type=notify` unit.
+func (a *App) notifySystemd(ctx context.Context) {
+	if !systemd.NotifyEnabled() {
+		return
+	}
+
+	a.notify(systemd.StateReady, systemd.Status(a.statusLine()))
+
+	var watchdog <-chan time.Time
+	if timeout, ok := systemd.WatchdogTimeout(); ok {
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+
+		watchdog = ticker.C
+		a.log.Debug().Msgf("app: systemd: watchdog enabled, timeout is %s", timeout)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			a.notify(systemd.StateStopping, systemd.Status("stopping"))
+			return
+		case <-watchdog:
+			a.notify(systemd.StateWatchdog)
+		}
+	}
+}
+
+// Sends the service states to systemd, once the application is started.
+func (a *App) notifyStatus() {
+	if !systemd.NotifyEnabled() || !a.startedWaiter.Is(true) {
+		return
+	}
+
+	a.notify(systemd.Status(a.statusLine()))
+}
+
+func (a *App) notify(states ...string) {
+	if _, err := systemd.Notify(states...); err != nil {
+		a.log.Warn().Err(err).Msg("app: systemd: failed to notify")
+	}
+}
+
+// Returns a summary of the service states,
+// e.g. "2/3 services running; db: restarting".
+func (a *App) statusLine() string {
+	status := a.Status()
+
+	running := 0
+	others := make([]string, 0)
+
+	for _, service := range status.Services {
+		if service.State == ServiceStateRunning {
+			running++
+			continue
+		}
+
+		others = append(others, fmt.Sprintf("%s: %s", service.Name, service.State))
+	}
+
+	line := fmt.Sprintf("%d/%d services running", running, len(status.Services))
+	if len(others) == 0 {
+		return line
+	}
+
+	return line + "; " + strings.Join(others, ", ")
+}
@@ -0,0 +1,93 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The first file descriptor passed by socket activation.
+const listenFDsStart = 3
+
+// A socket passed by socket activation.
+type Listener struct {
+	net.Listener
+
+	// A name from the FileDescriptorName= option of the socket unit,
+	// or the socket unit name by default.
+	Name string
+}
+
+// Returns listeners passed by socket activation, in the order of
+// the socket units, e.g. to set as `services.HTTPServer.Listener`.
+// Returns nothing if the process is not socket activated.
+// The LISTEN_* environment variables are unset, so child processes
+// don't inherit them, therefore the listeners could be taken only once.
+// Only stream sockets are supported.
+func Listeners() ([]Listener, error) {
+	return listeners(listenFDsStart)
+}
+
+// Returns the listener with the name passed by socket activation,
+// or nil if there is none. See `Listeners`.
+func ListenerByName(listeners []Listener, name string) net.Listener {
+	for _, listener := range listeners {
+		if listener.Name == name {
+			return listener.Listener
+		}
+	}
+
+	return nil
+}
+
+func listeners(start int) ([]Listener, error) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	listeners := make([]Listener, 0, count)
+
+	for i := 0; i < count; i++ {
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		file := os.NewFile(uintptr(start+i), name)
+
+		// The descriptor is duplicated, so the original one is closed anyway.
+		listener, err := net.FileListener(file)
+		_ = file.Close()
+
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+
+			for j := i + 1; j < count; j++ {
+				_ = os.NewFile(uintptr(start+j), "").Close()
+			}
+
+			return nil, errors.Wrapf(err, "socket '%s' is not a stream listener", name)
+		}
+
+		listeners = append(listeners, Listener{Listener: listener, Name: name})
+	}
+
+	return listeners, nil
+}
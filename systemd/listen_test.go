@@ -0,0 +1,82 @@
+//go:build unix
+
+package systemd
+
+import (
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a duplicated descriptor of a new TCP listener,
+// as if it was passed by socket activation.
+func activatedFD(t *testing.T) (int, string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	file, err := listener.(*net.TCPListener).File()
+	require.NoError(t, err)
+	defer file.Close()
+
+	fd, err := syscall.Dup(int(file.Fd()))
+	require.NoError(t, err)
+
+	return fd, listener.Addr().String()
+}
+
+func TestListeners(t *testing.T) {
+	t.Run("Not activated", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", "1")
+		t.Setenv("LISTEN_FDS", "1")
+
+		listeners, err := Listeners()
+		assert.NoError(t, err)
+		assert.Empty(t, listeners)
+	})
+
+	t.Run("Activated", func(t *testing.T) {
+		fd, addr := activatedFD(t)
+
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		t.Setenv("LISTEN_FDS", "1")
+		t.Setenv("LISTEN_FDNAMES", "http")
+
+		listeners, err := listeners(fd)
+		require.NoError(t, err)
+		require.Len(t, listeners, 1)
+		defer listeners[0].Close()
+
+		assert.Equal(t, "http", listeners[0].Name)
+		assert.Equal(t, addr, listeners[0].Addr().String())
+		assert.Equal(t, listeners[0].Listener, ListenerByName(listeners, "http"))
+		assert.Nil(t, ListenerByName(listeners, "grpc"))
+
+		_, set := os.LookupEnv("LISTEN_FDS")
+		assert.False(t, set)
+
+		go func() {
+			conn, err := net.Dial("tcp", addr)
+			if err == nil {
+				_, _ = io.WriteString(conn, "hello")
+				_ = conn.Close()
+			}
+		}()
+
+		conn, err := listeners[0].Accept()
+		require.NoError(t, err)
+		defer conn.Close()
+
+		body, err := io.ReadAll(conn)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(body))
+	})
+}
@@ -0,0 +1,79 @@
+// Package systemd implements the systemd service protocols without libsystemd:
+// readiness notifications (sd_notify), the watchdog, and socket activation.
+// See https://www.freedesktop.org/software/systemd/man/sd_notify.html.
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Notification states, see `Notify`.
+const (
+	// The service startup is finished.
+	StateReady = "READY=1"
+
+	// The service is shutting down.
+	StateStopping = "STOPPING=1"
+
+	// The service is reloading its configuration.
+	StateReloading = "RELOADING=1"
+
+	// Resets the watchdog timer.
+	StateWatchdog = "WATCHDOG=1"
+)
+
+// Returns a free-form status notification, e.g. `Notify(Status("3 services running"))`.
+func Status(status string) string {
+	return "STATUS=" + strings.ReplaceAll(status, "\n", " ")
+}
+
+// Sends the states to the service manager over the NOTIFY_SOCKET.
+// Returns false if NOTIFY_SOCKET is not set, i.e. the process is not
+// run by systemd as a `Type=notify` unit, which is not an error.
+func Notify(states ...string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+
+	// A leading "@" stands for the abstract namespace, which is handled by net.
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to connect to the notify socket")
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return false, errors.Wrap(err, "failed to notify the service manager")
+	}
+
+	return true, nil
+}
+
+// Returns the watchdog timeout of the service from WATCHDOG_USEC.
+// Returns false if the watchdog is disabled, or is meant for another process.
+// The service should notify `StateWatchdog` at least twice per the timeout.
+func WatchdogTimeout() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+
+	return time.Duration(usec) * time.Microsecond, true
+}
+
+// Reports whether the process is expected to send notifications,
+// i.e. whether NOTIFY_SOCKET is set.
+func NotifyEnabled() bool {
+	return os.Getenv("NOTIFY_SOCKET") != ""
+}
@@ -0,0 +1,81 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Listens on a fake NOTIFY_SOCKET until the test ends.
+func fakeNotifySocket(t *testing.T) *net.UnixConn {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	sent, err := Notify(StateReady)
+	assert.NoError(t, err)
+	assert.False(t, sent)
+
+	conn := fakeNotifySocket(t)
+
+	sent, err = Notify(StateReady, Status("all\nservices running"))
+	assert.NoError(t, err)
+	assert.True(t, sent)
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "READY=1\nSTATUS=all services running", string(buf[:n]))
+
+	t.Setenv("NOTIFY_SOCKET", filepath.Join(t.TempDir(), "missing.sock"))
+
+	sent, err = Notify(StateStopping)
+	assert.ErrorContains(t, err, "failed to connect to the notify socket")
+	assert.False(t, sent)
+}
+
+func TestWatchdogTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		usec    string
+		pid     string
+		timeout time.Duration
+		ok      bool
+	}{
+		{name: "disabled"},
+		{name: "enabled", usec: "30000000", timeout: time.Second * 30, ok: true},
+		{name: "own pid", usec: "1000", pid: strconv.Itoa(os.Getpid()), timeout: time.Millisecond, ok: true},
+		{name: "another pid", usec: "1000", pid: "1"},
+		{name: "invalid", usec: "soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WATCHDOG_USEC", tt.usec)
+			t.Setenv("WATCHDOG_PID", tt.pid)
+
+			timeout, ok := WatchdogTimeout()
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.timeout, timeout)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package appetizer
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApp_Systemd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", path)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", "")
+
+	srv := NewMockServicer(t)
+	srv.EXPECT().Init(mock.AnythingOfType("zerolog.Logger")).Return(nil).Once()
+	srv.EXPECT().Run(mock.AnythingOfType("*context.cancelCtx")).RunAndReturn(func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}).Once()
+
+	app := &App{Name: t.Name(), Services: []Service{{Name: "srv", Servicer: srv}}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	runCh := app.RunCh(ctx)
+
+	// Waits for a notification containing the state, returning it.
+	receive := func(state string) string {
+		t.Helper()
+
+		buf := make([]byte, 1024)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+		for {
+			n, err := conn.Read(buf)
+			require.NoError(t, err, "no '%s' notification", state)
+
+			if message := string(buf[:n]); strings.Contains(message, state) {
+				return message
+			}
+		}
+	}
+
+	assert.Contains(t, receive("READY=1"), "STATUS=")
+	assert.Equal(t, "WATCHDOG=1", receive("WATCHDOG=1"))
+
+	cancel()
+	assert.NoError(t, <-runCh)
+
+	assert.Equal(t, "STOPPING=1\nSTATUS=stopping", receive("STOPPING=1"))
+}
+
+func TestApp_statusLine(t *testing.T) {
+	app := &App{Services: []Service{{Name: "api"}, {Name: "db"}, {Name: "cache"}}}
+
+	app.setServiceState("api", ServiceStateRunning, nil)
+	app.setServiceState("db", ServiceStateRestarting, nil)
+
+	assert.Equal(t, "1/3 services running; db: restarting, cache: pending", app.statusLine())
+
+	app.setServiceState("db", ServiceStateRunning, nil)
+	app.setServiceState("cache", ServiceStateRunning, nil)
+
+	assert.Equal(t, "3/3 services running", app.statusLine())
+}